@@ -0,0 +1,127 @@
+// Conversion of the legacy charsets sent by old network gear to UTF-8
+
+package charset
+
+import (
+  "github.com/scalingdata/syslogparser"
+  "golang.org/x/text/encoding"
+  "golang.org/x/text/encoding/charmap"
+  "golang.org/x/text/encoding/japanese"
+  "strings"
+  "unicode/utf8"
+)
+
+// Charset names, as given by Detect
+const (
+  ASCII        = "us-ascii"
+  UTF8         = "utf-8"
+  LATIN1       = "iso-8859-1"
+  WINDOWS_1252 = "windows-1252"
+  SHIFT_JIS    = "shift_jis"
+)
+
+var ErrUnknownCharset = &syslogparser.ParserError{"Unknown charset"}
+
+// Charsets by name and common aliases
+var encodings = map[string]encoding.Encoding{
+  LATIN1:       charmap.ISO8859_1,
+  "latin1":     charmap.ISO8859_1,
+  WINDOWS_1252: charmap.Windows1252,
+  "cp1252":     charmap.Windows1252,
+  SHIFT_JIS:    japanese.ShiftJIS,
+  "sjis":       japanese.ShiftJIS,
+}
+
+/* Decoder gives the decoder converting the charset name to UTF-8. ASCII
+   and UTF-8 need none, their decoder is nil. */
+func Decoder(name string) (syslogparser.CharsetDecoder, error) {
+  name = strings.ToLower(name)
+
+  switch name {
+  case ASCII, UTF8, "ascii", "utf8":
+    return nil, nil
+  }
+
+  enc, ok := encodings[name]
+  if !ok {
+    return nil, ErrUnknownCharset
+  }
+
+  return func(b []byte) (string, error) {
+    decoded, err := enc.NewDecoder().Bytes(b)
+    if err != nil {
+      return "", err
+    }
+
+    return string(decoded), nil
+  }, nil
+}
+
+/* Detect guesses the charset of b among the ones of this package. It is a
+   heuristic : text that is valid in several charsets gets the first of
+   UTF-8, Shift-JIS, Windows-1252 and Latin-1 it is valid in, Windows-1252
+   being told apart from Latin-1 by the C1 range it prints. */
+func Detect(b []byte) string {
+  ascii := true
+  c1 := false
+
+  for _, c := range b {
+    if c >= 0x80 {
+      ascii = false
+      c1 = c1 || c <= 0x9F
+    }
+  }
+
+  if ascii {
+    return ASCII
+  }
+
+  if utf8.Valid(b) {
+    return UTF8
+  }
+
+  // Accented letters of Latin-1 text can pass for isolated double byte characters
+  if valid, consecutive := scanShiftJIS(b); valid && consecutive {
+    return SHIFT_JIS
+  }
+
+  if c1 {
+    return WINDOWS_1252
+  }
+
+  return LATIN1
+}
+
+/* scanShiftJIS tells if b is valid Shift-JIS, and if it holds two double
+   byte characters in a row as Japanese text does. */
+func scanShiftJIS(b []byte) (bool, bool) {
+  consecutive := false
+  // End of the last double byte character
+  lastEnd := -1
+
+  for i := 0; i < len(b); i++ {
+    c := b[i]
+
+    switch {
+    case c < 0x80, c >= 0xA1 && c <= 0xDF:
+      // ASCII or half width katakana
+    case c >= 0x81 && c <= 0x9F, c >= 0xE0 && c <= 0xFC:
+      if i+1 >= len(b) {
+        return false, consecutive
+      }
+
+      trail := b[i+1]
+      if trail < 0x40 || trail == 0x7F || trail > 0xFC {
+        return false, consecutive
+      }
+
+      consecutive = consecutive || lastEnd == i
+      i++
+      lastEnd = i + 1
+    default:
+      return false, consecutive
+    }
+  }
+
+  return true, consecutive
+}
@@ -0,0 +1,68 @@
+package charset
+
+import (
+  . "github.com/scalingdata/check"
+  "testing"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type CharsetTestSuite struct {
+}
+
+var _ = Suite(&CharsetTestSuite{})
+
+func (s *CharsetTestSuite) TestDecoder(c *C) {
+  fixtures := []struct {
+    name     string
+    buff     string
+    expected string
+  }{
+    {LATIN1, "caf\xE9", "café"},
+    {"Latin1", "caf\xE9", "café"},
+    {WINDOWS_1252, "\x80 \x93ok\x94", "€ “ok”"},
+    {SHIFT_JIS, "\x83\x65\x83\x58\x83\x67", "テスト"},
+  }
+
+  for _, f := range fixtures {
+    decoder, err := Decoder(f.name)
+    c.Assert(err, IsNil)
+
+    obtained, err := decoder([]byte(f.buff))
+    c.Assert(err, IsNil)
+    c.Assert(obtained, Equals, f.expected, Commentf(f.name))
+  }
+}
+
+func (s *CharsetTestSuite) TestDecoder_NotNeeded(c *C) {
+  for _, name := range []string{ASCII, UTF8, "UTF8"} {
+    decoder, err := Decoder(name)
+    c.Assert(err, IsNil)
+    c.Assert(decoder, IsNil)
+  }
+}
+
+func (s *CharsetTestSuite) TestDecoder_Unknown(c *C) {
+  _, err := Decoder("ebcdic")
+  c.Assert(err, Equals, ErrUnknownCharset)
+}
+
+func (s *CharsetTestSuite) TestDetect(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected string
+  }{
+    {"plain text", ASCII},
+    {"caf\xC3\xA9", UTF8},
+    {"caf\xE9 cr\xE8me", LATIN1},
+    {"\x93caf\xE9\x94", WINDOWS_1252},
+    {"\x83\x65\x83\x58\x83\x67 ok", SHIFT_JIS},
+    // Half width katakana only, valid Latin-1 as well
+    {"\xB1\xB2\xB3", LATIN1},
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Detect([]byte(f.buff)), Equals, f.expected, Commentf("%q", f.buff))
+  }
+}
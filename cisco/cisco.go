@@ -0,0 +1,653 @@
+// Parser of the syslog dialect of Cisco IOS, NX-OS and ASA devices
+
+package cisco
+
+import (
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/internal/zerocopy"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "time"
+)
+
+// Reported in syslogparser.PositionError
+const FORMAT = "Cisco"
+
+// Fields of a Cisco message, as reported by syslogparser.PositionError
+const (
+  FIELD_SEQUENCE = "SEQUENCE"
+  FIELD_MNEMONIC = "MNEMONIC"
+)
+
+// Bytes Detect looks for the %FACILITY-SEVERITY-MNEMONIC in
+const MAX_HEADER_LEN = 128
+
+var (
+  ErrMnemonicNotFound = &syslogparser.ParserError{"No %FACILITY-SEVERITY-MNEMONIC found"}
+  ErrMnemonicInvalid  = &syslogparser.ParserError{"Invalid %FACILITY-SEVERITY-MNEMONIC"}
+  ErrDeviceEmpty      = &syslogparser.ParserError{"Empty device name"}
+)
+
+/* Parser reads the messages of Cisco devices, eg.
+
+     <189>123: router1: *Mar  1 18:46:11.123 UTC: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up
+     <189>: 2024 Mar  1 18:46:11 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up
+     <166>Mar 01 2024 18:46:11 asa1 : %ASA-6-302013: Built inbound TCP connection
+
+   The sequence number, device and timestamp are optional, so is the PRI,
+   the facility of messages without one being Local7, the default of the
+   devices. The severity is the one of the %FACILITY-SEVERITY-MNEMONIC. */
+type Parser struct {
+  buff     []byte
+  cursor   int
+  l        int
+  priority syslogparser.Priority
+  header   header
+  tag      tag
+  content  string
+  parseSuccessful bool
+  // Field being parsed, reported on errors
+  field    string
+  // Fields share buff instead of copying it, see SetZeroCopy
+  zeroCopy bool
+  // Time zone of timestamps without a zone or with one missing from Zones, time.Local when nil
+  Location *time.Location
+  // Time zones by the abbreviation devices print, eg. "CET", UTC and GMT being known
+  Zones map[string]*time.Location
+  /* Timestamps without a year get the year putting them closest to
+     ReferenceTime, or to the time given by TimeFunction when it is zero. */
+  ReferenceTime time.Time
+  // Gives the receive time of messages without timestamp, time.Now when nil
+  TimeFunction func() time.Time
+}
+
+type header struct {
+  sequence       string
+  device         string
+  timestamp      time.Time
+  zone           string
+  unsynchronized bool
+  yearInferred   bool
+  timestampInfo  message.TimestampInfo
+}
+
+// The %FACILITY-SEVERITY-MNEMONIC, eg. %LINK-3-UPDOWN
+type tag struct {
+  messageId string
+  facility  string
+  severity  int
+  mnemonic  string
+}
+
+func NewParser(buff *[]byte) *Parser {
+  return &Parser{
+    buff: *buff,
+    l:    len(*buff),
+  }
+}
+
+// Reset prepares the parser for a new message, keeping its settings
+func (p *Parser) Reset(buff *[]byte) {
+  p.buff = *buff
+  p.cursor = 0
+  p.l = len(*buff)
+  p.priority = syslogparser.Priority{}
+  p.header = header{}
+  p.tag = tag{}
+  p.content = ""
+  p.parseSuccessful = false
+  p.field = ""
+}
+
+/* SetZeroCopy makes the parsed fields share the memory of the buffer
+   instead of copying it, the buffer must then not be modified while they
+   are in use. */
+func (p *Parser) SetZeroCopy(zeroCopy bool) {
+  p.zeroCopy = zeroCopy
+}
+
+/* Detect tells how likely buff is a Cisco message : CERTAIN when a
+   %FACILITY-SEVERITY-MNEMONIC starts a word of its first MAX_HEADER_LEN
+   bytes, only LIKELY when it follows the TAG of an RFC 3164 message, as
+   in the explicit-priority messages of Junos or contents quoting Cisco
+   messages. Mnemonics following a TAG with a pid are not Cisco's. */
+func Detect(buff []byte) syslogparser.Confidence {
+  l := len(buff)
+  if l > MAX_HEADER_LEN {
+    l = MAX_HEADER_LEN
+  }
+
+  for i := 0; i < l; i++ {
+    if buff[i] != '%' || (i > 0 && buff[i-1] != ' ' && buff[i-1] != syslogparser.PRI_PART_END) {
+      continue
+    }
+
+    if i >= 3 && buff[i-2] == ':' && buff[i-3] == ']' {
+      continue
+    }
+
+    end := i + 1
+    for end < len(buff) && buff[end] != ':' && buff[end] != ' ' {
+      end++
+    }
+
+    if end < len(buff) && buff[end] == ':' {
+      if _, ok := splitMessageId(buff[i+1 : end]); ok {
+        if followsTag(buff, i) {
+          return syslogparser.LIKELY
+        }
+
+        return syslogparser.CERTAIN
+      }
+    }
+  }
+
+  return syslogparser.NO_MATCH
+}
+
+/* followsTag tells if the mnemonic at i follows the "TAG:" of an RFC 3164
+   message, a word ending with a colon that is neither a sequence number, a
+   time nor a zone abbreviation, eg. "mgd:". */
+func followsTag(buff []byte, i int) bool {
+  if rfc3164.Detect(buff) < syslogparser.LIKELY {
+    return false
+  }
+
+  start := 0
+  for end := 0; end < i; end++ {
+    if buff[end] != ' ' {
+      continue
+    }
+
+    if end-start > 1 && buff[end-1] == ':' && isTag(buff[start:end-1]) {
+      return true
+    }
+    start = end + 1
+  }
+
+  return false
+}
+
+func isTag(word []byte) bool {
+  digits, upper := 0, 0
+  for _, c := range word {
+    switch {
+    case syslogparser.IsDigit(c):
+      digits++
+    case c >= 'A' && c <= 'Z':
+      upper++
+    case c >= 'a' && c <= 'z', c == '-', c == '_', c == '.', c == '/':
+    default:
+      return false
+    }
+  }
+
+  return digits < len(word) && upper < len(word)
+}
+
+func (p *Parser) Parse() error {
+  p.field = syslogparser.FIELD_PRI
+  pri, hasPri, err := p.parsePriority()
+  if err != nil {
+    return p.parseError(err)
+  }
+
+  hdr, err := p.parseHeader()
+  if err != nil {
+    return p.parseError(err)
+  }
+
+  p.field = FIELD_MNEMONIC
+  t, err := p.parseTag()
+  if err != nil {
+    return p.parseError(err)
+  }
+
+  if !hasPri {
+    pri, _ = syslogparser.ComputePriority(message.Local7, message.Severity(t.severity))
+  }
+
+  p.priority = pri
+  p.header = hdr
+  p.tag = t
+  p.content = p.str(p.buff[p.cursor:p.l])
+  p.parseSuccessful = true
+
+  return nil
+}
+
+func (p *Parser) Dump() syslogparser.LogParts {
+  return syslogparser.LogParts{
+    "timestamp":         p.header.timestamp,
+    "hostname":          p.header.device,
+    "sequence":          p.header.sequence,
+    "zone":              p.header.zone,
+    "message_id":        p.tag.messageId,
+    "facility_mnemonic": p.tag.facility,
+    "mnemonic":          p.tag.mnemonic,
+    "content":           p.content,
+    "priority":          p.priority.P,
+    "facility":          p.priority.F.Value,
+    "severity":          p.tag.severity,
+  }
+}
+
+func (p *Parser) Message() message.IMessage {
+  if !p.parseSuccessful {
+    return message.NewUnparsableMessage(&p.buff)
+  }
+
+  return &CiscoMessage{
+    rawMsg:         &p.buff,
+    ts:             p.header.timestamp,
+    facility:       message.Facility(p.priority.F.Value),
+    severity:       message.Severity(p.tag.severity),
+    hostname:       p.header.device,
+    message:        p.content,
+    sequence:       p.header.sequence,
+    zone:           p.header.zone,
+    unsynchronized: p.header.unsynchronized,
+    yearInferred:   p.header.yearInferred,
+    timestampInfo:  p.header.timestampInfo,
+    messageId:      p.tag.messageId,
+    facilityName:   p.tag.facility,
+    mnemonic:       p.tag.mnemonic,
+  }
+}
+
+func (p *Parser) str(b []byte) string {
+  if p.zeroCopy {
+    return zerocopy.String(b)
+  }
+
+  return string(b)
+}
+
+// parseError locates err at the field and position the parser stopped at
+func (p *Parser) parseError(err error) error {
+  return syslogparser.NewPositionError(FORMAT, p.field, p.cursor, err)
+}
+
+// parsePriority reads the PRI if there is one, and tells so
+func (p *Parser) parsePriority() (syslogparser.Priority, bool, error) {
+  if p.l == 0 || p.buff[0] != syslogparser.PRI_PART_START {
+    return syslogparser.Priority{}, false, nil
+  }
+
+  pri, err := syslogparser.ParsePriority(p.buff, &p.cursor, p.l)
+  return pri, true, err
+}
+
+/* parseHeader reads what comes before the %FACILITY-SEVERITY-MNEMONIC : an
+   optional sequence number, then the timestamp and the device in any order,
+   IOS printing the device first and NX-OS and ASA after the timestamp. */
+func (p *Parser) parseHeader() (header, error) {
+  hdr := header{}
+
+  p.field = FIELD_SEQUENCE
+  hdr.sequence = p.parseSequence()
+
+  hasTimestamp := false
+  for p.cursor < p.l && p.buff[p.cursor] != '%' {
+    if !hasTimestamp {
+      p.field = syslogparser.FIELD_TIMESTAMP
+      ok, err := p.parseTimestamp(&hdr)
+      if err != nil {
+        return hdr, err
+      }
+
+      if ok {
+        hasTimestamp = true
+        continue
+      }
+    }
+
+    if hdr.device != "" {
+      p.field = FIELD_MNEMONIC
+      return hdr, ErrMnemonicNotFound
+    }
+
+    p.field = syslogparser.FIELD_HOSTNAME
+    device, err := p.parseDevice()
+    if err != nil {
+      return hdr, err
+    }
+    hdr.device = device
+  }
+
+  if p.cursor >= p.l {
+    p.field = FIELD_MNEMONIC
+    return hdr, ErrMnemonicNotFound
+  }
+
+  if !hasTimestamp {
+    hdr.timestamp = p.receiveTime()
+    hdr.timestampInfo = message.TimestampInfo{Absent: true}
+  }
+
+  return hdr, nil
+}
+
+/* parseSequence reads the "123: " IOS starts messages with when sequence
+   numbers are on, NX-OS leaves the number out. */
+func (p *Parser) parseSequence() string {
+  start := p.cursor
+  for p.cursor < p.l && syslogparser.IsDigit(p.buff[p.cursor]) {
+    p.cursor++
+  }
+
+  if p.cursor < p.l && p.buff[p.cursor] == ':' && (p.cursor+1 == p.l || p.buff[p.cursor+1] == ' ') {
+    sequence := p.str(p.buff[start:p.cursor])
+    p.cursor++
+    p.skipSpaces()
+    return sequence
+  }
+
+  p.cursor = start
+  return ""
+}
+
+/* parseTimestamp reads a timestamp if there is one at the cursor, and tells
+   so. It is one of
+
+     [*|.]Mmm dd [yyyy ]hh:mm:ss[.fff][ ZONE][:]   IOS, ASA
+     yyyy Mmm dd hh:mm:ss[.fff][ ZONE][:]          NX-OS
+
+   a leading * or . telling that the clock of the device is not
+   synchronized. */
+func (p *Parser) parseTimestamp(hdr *header) (bool, error) {
+  start := p.cursor
+  c := p.cursor
+  unsynchronized := false
+
+  if c < p.l && (p.buff[c] == '*' || p.buff[c] == '.') {
+    unsynchronized = true
+    c++
+  }
+
+  year, hasYear := p.parseYear(&c)
+
+  if c+4 > p.l || p.buff[c+3] != ' ' {
+    return false, nil
+  }
+
+  month := monthOf(p.buff[c : c+3])
+  if month == 0 {
+    return false, nil
+  }
+  c += 4
+
+  if c < p.l && p.buff[c] == ' ' {
+    c++
+  }
+
+  p.cursor = c
+  dayStart := c
+  day, ok := p.parseNumber(&c, 1, 2)
+  if !ok || c >= p.l || p.buff[c] != ' ' {
+    return false, syslogparser.ErrTimestampUnknownFormat
+  }
+  c++
+
+  if !hasYear {
+    year, hasYear = p.parseYear(&c)
+  }
+
+  p.cursor = c
+  hour, ok1 := p.parseNumber(&c, 2, 2)
+  ok2 := p.expect(&c, ':')
+  minute, ok3 := p.parseNumber(&c, 2, 2)
+  ok4 := p.expect(&c, ':')
+  second, ok5 := p.parseNumber(&c, 2, 2)
+  if !(ok1 && ok2 && ok3 && ok4 && ok5) || hour > 23 || minute > 59 || second > 59 {
+    return false, syslogparser.ErrTimestampUnknownFormat
+  }
+
+  nanosecond, precision := 0, 0
+  if c < p.l && p.buff[c] == '.' {
+    c++
+    for c < p.l && syslogparser.IsDigit(p.buff[c]) {
+      if precision < 9 {
+        nanosecond = nanosecond*10 + int(p.buff[c]-'0')
+        precision++
+      }
+      c++
+    }
+
+    for i := precision; i < 9; i++ {
+      nanosecond *= 10
+    }
+  }
+
+  // The zone is printed right before the colon ending the timestamp
+  zone := ""
+  if c < p.l && p.buff[c] == ' ' {
+    end := c + 1
+    for end < p.l && p.buff[end] >= 'A' && p.buff[end] <= 'Z' {
+      end++
+    }
+
+    if end > c+1 && end < p.l && p.buff[end] == ':' {
+      zone = p.str(p.buff[c+1 : end])
+      c = end
+    }
+  }
+  end := c
+
+  loc := p.zoneLocation(zone)
+  ts := time.Date(year, month, day, hour, minute, second, nanosecond, loc)
+  if ts.Day() != day {
+    p.cursor = dayStart
+    return false, syslogparser.ErrTimestampUnknownFormat
+  }
+
+  if !hasYear {
+    ts = syslogparser.InferYear(ts, p.referenceTime())
+  }
+
+  _, offset := ts.Zone()
+  hdr.timestamp = ts.UTC()
+  hdr.zone = zone
+  hdr.unsynchronized = unsynchronized
+  hdr.yearInferred = !hasYear
+  hdr.timestampInfo = message.TimestampInfo{
+    Raw:       p.str(p.buff[start:end]),
+    Precision: precision,
+    Offset:    offset,
+  }
+
+  p.cursor = end
+  p.expect(&p.cursor, ':')
+  p.skipSpaces()
+
+  return true, nil
+}
+
+// parseYear reads the 4 digits and space of a year at c if there are some
+func (p *Parser) parseYear(c *int) (int, bool) {
+  if *c+5 > p.l || p.buff[*c+4] != ' ' {
+    return 0, false
+  }
+
+  from := *c
+  year, ok := p.parseNumber(&from, 4, 4)
+  if !ok {
+    return 0, false
+  }
+
+  *c = from + 1
+  return year, true
+}
+
+// parseNumber reads between min and max digits at c
+func (p *Parser) parseNumber(c *int, min int, max int) (int, bool) {
+  n := 0
+  digits := 0
+
+  for *c < p.l && digits < max && syslogparser.IsDigit(p.buff[*c]) {
+    n = n*10 + int(p.buff[*c]-'0')
+    digits++
+    *c++
+  }
+
+  return n, digits >= min
+}
+
+// expect skips the byte b at c, and tells if it was there
+func (p *Parser) expect(c *int, b byte) bool {
+  if *c < p.l && p.buff[*c] == b {
+    *c++
+    return true
+  }
+
+  return false
+}
+
+func (p *Parser) skipSpaces() {
+  for p.cursor < p.l && p.buff[p.cursor] == ' ' {
+    p.cursor++
+  }
+}
+
+// parseDevice reads the name of the device, followed by a space or a colon, "asa1 : "
+func (p *Parser) parseDevice() (string, error) {
+  start := p.cursor
+  for p.cursor < p.l && p.buff[p.cursor] != ' ' && p.buff[p.cursor] != ':' {
+    p.cursor++
+  }
+
+  if p.cursor == start {
+    return "", ErrDeviceEmpty
+  }
+  device := p.str(p.buff[start:p.cursor])
+
+  p.skipSpaces()
+  if p.expect(&p.cursor, ':') {
+    p.skipSpaces()
+  }
+
+  return device, nil
+}
+
+// parseTag reads the %FACILITY-SEVERITY-MNEMONIC and its colon
+func (p *Parser) parseTag() (tag, error) {
+  t := tag{}
+
+  // Skip the %
+  p.cursor++
+  start := p.cursor
+
+  for p.cursor < p.l && p.buff[p.cursor] != ':' && p.buff[p.cursor] != ' ' {
+    p.cursor++
+  }
+
+  if p.cursor >= p.l || p.buff[p.cursor] != ':' {
+    return t, ErrMnemonicInvalid
+  }
+
+  id := p.buff[start:p.cursor]
+  parts, ok := splitMessageId(id)
+  if !ok {
+    p.cursor = start
+    return t, ErrMnemonicInvalid
+  }
+
+  t.messageId = p.str(id)
+  t.facility = p.str(id[:parts.facilityEnd])
+  t.severity = int(id[parts.severity] - '0')
+  t.mnemonic = p.str(id[parts.severity+2:])
+
+  p.cursor++
+  p.expect(&p.cursor, ' ')
+
+  return t, nil
+}
+
+// Positions of the parts of a FACILITY[-SUBFACILITY]-SEVERITY-MNEMONIC
+type messageIdParts struct {
+  facilityEnd int
+  severity    int
+}
+
+/* splitMessageId finds the parts of id, the severity being the single
+   digit before the last dash. Sub-facilities, eg. the SP of
+   C6KPWR-SP-4-DISABLED, only appear in the message ID. */
+func splitMessageId(id []byte) (messageIdParts, bool) {
+  parts := messageIdParts{facilityEnd: -1}
+
+  for i, c := range id {
+    switch {
+    case c == '-':
+      if parts.facilityEnd < 0 {
+        parts.facilityEnd = i
+      }
+    case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || syslogparser.IsDigit(c) || c == '_':
+    default:
+      return parts, false
+    }
+  }
+
+  // The severity is at len-3 at the latest, followed by a dash and a mnemonic
+  last := -1
+  for i := len(id) - 1; i >= 0; i-- {
+    if id[i] == '-' {
+      last = i
+      break
+    }
+  }
+
+  parts.severity = last - 1
+  if parts.facilityEnd <= 0 || last < 3 || last == len(id)-1 || id[last-2] != '-' ||
+    id[parts.severity] < '0' || id[parts.severity] > '7' {
+    return parts, false
+  }
+
+  return parts, true
+}
+
+func (p *Parser) zoneLocation(zone string) *time.Location {
+  switch zone {
+  case "UTC", "GMT":
+    return time.UTC
+  }
+
+  if loc, ok := p.Zones[zone]; ok && zone != "" {
+    return loc
+  }
+
+  if p.Location != nil {
+    return p.Location
+  }
+
+  return time.Local
+}
+
+func (p *Parser) receiveTime() time.Time {
+  if p.TimeFunction == nil {
+    return time.Now().UTC()
+  }
+
+  return p.TimeFunction().UTC()
+}
+
+// referenceTime is the time the year of timestamps is inferred from
+func (p *Parser) referenceTime() time.Time {
+  if !p.ReferenceTime.IsZero() {
+    return p.ReferenceTime
+  }
+
+  return p.receiveTime()
+}
+
+var months = [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// monthOf gives the month abbreviated as b, 0 if b is not one
+func monthOf(b []byte) time.Month {
+  for i, m := range months {
+    if string(b) == m {
+      return time.Month(i + 1)
+    }
+  }
+
+  return 0
+}
@@ -0,0 +1,243 @@
+package cisco
+
+import (
+  "errors"
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/message"
+  "testing"
+  "time"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type CiscoTestSuite struct {
+}
+
+var (
+  _ = Suite(&CiscoTestSuite{})
+  receiveTime = time.Date(2024, time.March, 2, 8, 0, 0, 0, time.UTC)
+)
+
+func newTestParser(buff string) *Parser {
+  b := []byte(buff)
+  p := NewParser(&b)
+  p.Location = time.UTC
+  p.TimeFunction = func() time.Time { return receiveTime }
+  return p
+}
+
+func (s *CiscoTestSuite) TestParser_IOS(c *C) {
+  p := newTestParser("<189>123: router1: *Mar  1 18:46:11.123 UTC: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up")
+  c.Assert(p.Parse(), IsNil)
+
+  c.Assert(p.Dump(), DeepEquals, syslogparser.LogParts{
+    "timestamp":         time.Date(2024, time.March, 1, 18, 46, 11, 123000000, time.UTC),
+    "hostname":          "router1",
+    "sequence":          "123",
+    "zone":              "UTC",
+    "message_id":        "LINK-3-UPDOWN",
+    "facility_mnemonic": "LINK",
+    "mnemonic":          "UPDOWN",
+    "content":           "Interface Fa0/1, changed state to up",
+    "priority":          189,
+    "facility":          23,
+    "severity":          3,
+  })
+
+  msg, ok := p.Message().(IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert(msg.Severity(), Equals, message.Error)
+  c.Assert(msg.Facility(), Equals, message.Local7)
+  c.Assert(msg.Process(), Equals, "LINK")
+  c.Assert(msg.Unsynchronized(), Equals, true)
+  c.Assert(msg.YearInferred(), Equals, true)
+  c.Assert(msg.TimestampInfo(), Equals, message.TimestampInfo{
+    Raw:       "*Mar  1 18:46:11.123 UTC",
+    Precision: 3,
+  })
+}
+
+func (s *CiscoTestSuite) TestParser_Dialects(c *C) {
+  fixtures := []struct {
+    buff      string
+    sequence  string
+    device    string
+    timestamp time.Time
+    messageId string
+    content   string
+  }{
+    // IOS without sequence number nor device
+    {
+      "<189>Mar  1 18:46:11: %SYS-5-CONFIG_I: Configured from console by vty0",
+      "", "", time.Date(2024, time.March, 1, 18, 46, 11, 0, time.UTC),
+      "SYS-5-CONFIG_I", "Configured from console by vty0",
+    },
+    // IOS with the year and leading zeros
+    {
+      "<189>000042: Mar  1 2023 18:46:11.5 GMT: %LINEPROTO-5-UPDOWN: Line protocol on Interface Gi0/1, changed state to down",
+      "000042", "", time.Date(2023, time.March, 1, 18, 46, 11, 500000000, time.UTC),
+      "LINEPROTO-5-UPDOWN", "Line protocol on Interface Gi0/1, changed state to down",
+    },
+    // Sub-facility
+    {
+      "<188>12: .Mar  1 18:46:11 UTC: %C6KPWR-SP-4-DISABLED: power to module in slot 3 set off",
+      "12", "", time.Date(2024, time.March, 1, 18, 46, 11, 0, time.UTC),
+      "C6KPWR-SP-4-DISABLED", "power to module in slot 3 set off",
+    },
+    // NX-OS
+    {
+      "<189>: 2024 Mar  1 18:46:11 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up in mode access",
+      "", "", time.Date(2024, time.March, 1, 18, 46, 11, 0, time.UTC),
+      "ETHPORT-5-IF_UP", "Interface Ethernet1/1 is up in mode access",
+    },
+    // NX-OS with its hostname as origin-id
+    {
+      "<189>: 2024 Mar  1 18:46:11.040 UTC: switch1 %VSHD-5-VSHD_SYSLOG_CONFIG_I: Configured from vty by admin",
+      "", "switch1", time.Date(2024, time.March, 1, 18, 46, 11, 40000000, time.UTC),
+      "VSHD-5-VSHD_SYSLOG_CONFIG_I", "Configured from vty by admin",
+    },
+    // ASA with timestamp and device-id
+    {
+      "<166>Mar 01 2024 18:46:11 asa1 : %ASA-6-302013: Built inbound TCP connection 1 for outside:10.0.0.1/443",
+      "", "asa1", time.Date(2024, time.March, 1, 18, 46, 11, 0, time.UTC),
+      "ASA-6-302013", "Built inbound TCP connection 1 for outside:10.0.0.1/443",
+    },
+    // ASA with neither
+    {
+      "<166>%ASA-6-302013: Built inbound TCP connection",
+      "", "", receiveTime,
+      "ASA-6-302013", "Built inbound TCP connection",
+    },
+    // From a file, without PRI
+    {
+      "%ASA-4-106023: Deny tcp src outside:10.0.0.1/1 dst inside:10.0.0.2/22",
+      "", "", receiveTime,
+      "ASA-4-106023", "Deny tcp src outside:10.0.0.1/1 dst inside:10.0.0.2/22",
+    },
+  }
+
+  for _, f := range fixtures {
+    p := newTestParser(f.buff)
+    c.Assert(p.Parse(), IsNil, Commentf(f.buff))
+
+    msg := p.Message().(IMessage)
+    c.Assert(msg.Sequence(), Equals, f.sequence, Commentf(f.buff))
+    c.Assert(msg.Hostname(), Equals, f.device, Commentf(f.buff))
+    c.Assert(msg.TimeStamp(), Equals, f.timestamp, Commentf(f.buff))
+    c.Assert(msg.MessageId(), Equals, f.messageId, Commentf(f.buff))
+    c.Assert(msg.Message(), Equals, f.content, Commentf(f.buff))
+  }
+}
+
+func (s *CiscoTestSuite) TestParser_ASA(c *C) {
+  p := newTestParser("<166>%ASA-6-302013: Built inbound TCP connection")
+  c.Assert(p.Parse(), IsNil)
+
+  msg := p.Message().(IMessage)
+  c.Assert(msg.FacilityMnemonic(), Equals, "ASA")
+  c.Assert(msg.Mnemonic(), Equals, "302013")
+  c.Assert(msg.Severity(), Equals, message.Info)
+  c.Assert(msg.Facility(), Equals, message.Local4)
+  c.Assert(msg.TimestampInfo().Absent, Equals, true)
+}
+
+func (s *CiscoTestSuite) TestParser_NoPriority(c *C) {
+  p := newTestParser("%ASA-4-106023: Deny tcp")
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Dump()["priority"], Equals, 188)
+}
+
+func (s *CiscoTestSuite) TestParser_Zones(c *C) {
+  cet := time.FixedZone("CET", 3600)
+  p := newTestParser("<189>1: Mar  1 2024 18:46:11 CET: %SYS-5-RESTART: System restarted")
+  p.Zones = map[string]*time.Location{"CET": cet}
+  c.Assert(p.Parse(), IsNil)
+
+  msg := p.Message().(IMessage)
+  c.Assert(msg.TimeStamp(), Equals, time.Date(2024, time.March, 1, 17, 46, 11, 0, time.UTC))
+  c.Assert(msg.Zone(), Equals, "CET")
+  c.Assert(msg.TimestampInfo().Offset, Equals, 3600)
+  c.Assert(msg.YearInferred(), Equals, false)
+
+  // Unknown zones are in Location
+  p = newTestParser("<189>1: Mar  1 2024 18:46:11 PST: %SYS-5-RESTART: System restarted")
+  p.Location = time.FixedZone("", -8*3600)
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().TimeStamp(), Equals, time.Date(2024, time.March, 2, 2, 46, 11, 0, time.UTC))
+}
+
+func (s *CiscoTestSuite) TestParser_Invalid(c *C) {
+  fixtures := []struct {
+    buff   string
+    field  string
+    offset int
+    err    error
+  }{
+    {"<189>123: router1: *Mar  1 18:46:11 UTC: LINK-3-UPDOWN: up", FIELD_MNEMONIC, 41, ErrMnemonicNotFound},
+    {"<189>123: router1: *Mar 32 18:46:11 UTC: %LINK-3-UPDOWN: up", syslogparser.FIELD_TIMESTAMP, 24, syslogparser.ErrTimestampUnknownFormat},
+    {"<189>123: router1: *Mar  1 24:46:11 UTC: %LINK-3-UPDOWN: up", syslogparser.FIELD_TIMESTAMP, 27, syslogparser.ErrTimestampUnknownFormat},
+    {"<189>Mar  1 18:46:11: %LINK-9-UPDOWN: up", FIELD_MNEMONIC, 23, ErrMnemonicInvalid},
+    {"<189>Mar  1 18:46:11: %LINK_UPDOWN: up", FIELD_MNEMONIC, 23, ErrMnemonicInvalid},
+    {"<189>Mar  1 18:46:11: %LINK-3-UPDOWN up", FIELD_MNEMONIC, 36, ErrMnemonicInvalid},
+    {"<34>Oct 11 22:14:15 mymachine su: 'su root' failed", FIELD_MNEMONIC, 30, ErrMnemonicNotFound},
+    {"<190", syslogparser.FIELD_PRI, 0, syslogparser.ErrPriorityNoEnd},
+  }
+
+  for _, f := range fixtures {
+    p := newTestParser(f.buff)
+    err := p.Parse()
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(err, &parseErr), Equals, true, Commentf(f.buff))
+    c.Assert(parseErr.Format, Equals, FORMAT)
+    c.Assert(parseErr.Field, Equals, f.field, Commentf(f.buff))
+    c.Assert(parseErr.Offset, Equals, f.offset, Commentf(f.buff))
+    c.Assert(errors.Is(err, f.err), Equals, true, Commentf(f.buff))
+
+    _, unparsable := p.Message().(*message.UnparsableMessage)
+    c.Assert(unparsable, Equals, true)
+  }
+}
+
+func (s *CiscoTestSuite) TestDetect(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected syslogparser.Confidence
+  }{
+    {"<189>123: router1: *Mar  1 18:46:11.123 UTC: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up", syslogparser.CERTAIN},
+    {"<166>%ASA-6-302013: Built inbound TCP connection", syslogparser.CERTAIN},
+    {"%ASA-6-302013: Built inbound TCP connection", syslogparser.CERTAIN},
+    {"<34>Oct 11 22:14:15 mymachine su: 'su root' failed", syslogparser.NO_MATCH},
+    {"<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts.", syslogparser.NO_MATCH},
+    {"<34>Oct 11 22:14:15 mymachine su: 100%-3-x: done", syslogparser.NO_MATCH},
+    {"<189>Mar  1 12:00:00 srx1 mgd[3046]: %INTERACT-6-UI_COMMIT: User 'admin'", syslogparser.NO_MATCH},
+    {"<189>Mar  1 12:00:00 srx1 mgd: %INTERACT-6-UI_COMMIT: User 'admin'", syslogparser.LIKELY},
+    {"<34>Oct 11 22:14:15 nms poller: router1 sent %LINK-3-UPDOWN: Interface Fa0/1", syslogparser.LIKELY},
+    {"<189>Mar  1 12:00:00.123 UTC: router %SYS-5-CONFIG_I: Configured from console", syslogparser.CERTAIN},
+    {"<189>*Mar  1 18:46:11: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up", syslogparser.CERTAIN},
+    {"<189>123: router1: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up", syslogparser.CERTAIN},
+    {"<166>Mar 01 2024 18:46:11 asa1 : %ASA-6-302013: Built inbound TCP connection", syslogparser.CERTAIN},
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Detect([]byte(f.buff)), Equals, f.expected, Commentf(f.buff))
+  }
+}
+
+func (s *CiscoTestSuite) TestReset_ZeroAllocations(c *C) {
+  buff := []byte("<189>123: router1: *Mar  1 18:46:11.123 UTC: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up")
+  p := NewParser(&buff)
+  p.Location = time.UTC
+  p.ReferenceTime = receiveTime
+  p.SetZeroCopy(true)
+
+  allocs := testing.AllocsPerRun(100, func() {
+    p.Reset(&buff)
+    if err := p.Parse(); err != nil {
+      panic(err)
+    }
+  })
+  c.Assert(allocs, Equals, 0.0)
+}
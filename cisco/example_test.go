@@ -0,0 +1,19 @@
+package cisco_test
+
+import (
+  "fmt"
+  "github.com/scalingdata/syslogparser/cisco"
+)
+
+func ExampleNewParser() {
+  b := "<189>123: router1: *Mar  1 18:46:11.123 UTC: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up"
+  buff := []byte(b)
+
+  p := cisco.NewParser(&buff)
+  err := p.Parse()
+  if err != nil {
+    panic(err)
+  }
+
+  fmt.Println(p.Dump())
+}
@@ -0,0 +1,110 @@
+package cisco
+
+import (
+  message "github.com/scalingdata/syslogparser/message"
+  "time"
+)
+
+/* IMessage exposes the Cisco specific fields on top of the common
+   message.IMessage, type assert a message.IMessage to get to them. */
+type IMessage interface {
+  message.IMessage
+  // Sequence number of the message, empty when the device does not number them
+  Sequence() string
+  // FACILITY-SEVERITY-MNEMONIC of the message, eg. LINK-3-UPDOWN or ASA-6-302013
+  MessageId() string
+  // Facility of the message ID, eg. LINK
+  FacilityMnemonic() string
+  // Mnemonic of the message ID, eg. UPDOWN or 302013
+  Mnemonic() string
+  // Time zone abbreviation printed after the timestamp, eg. UTC
+  Zone() string
+  // The clock of the device was not synchronized, its timestamp starts with * or .
+  Unsynchronized() bool
+  // The timestamp had no year, it was inferred, see Parser.ReferenceTime
+  YearInferred() bool
+  TimestampInfo() message.TimestampInfo
+}
+
+type CiscoMessage struct {
+  rawMsg *[]byte
+  ts time.Time
+  facility message.Facility
+  severity message.Severity
+  hostname string
+  message string
+  sequence string
+  zone string
+  unsynchronized bool
+  yearInferred bool
+  timestampInfo message.TimestampInfo
+  messageId string
+  facilityName string
+  mnemonic string
+}
+
+func (self CiscoMessage) RawMessage() *[]byte {
+  return self.rawMsg
+}
+
+func (self CiscoMessage) TimeStamp() time.Time {
+  return self.ts
+}
+
+func (self CiscoMessage) Pid() string {
+  return ""
+}
+
+func (self CiscoMessage) Facility() message.Facility {
+  return self.facility
+}
+
+func (self CiscoMessage) Severity() message.Severity {
+  return self.severity
+}
+
+// Process is the facility of the message ID, the closest Cisco has to a program name
+func (self CiscoMessage) Process() string {
+  return self.facilityName
+}
+
+// Hostname is the device name, empty when the device does not send it
+func (self CiscoMessage) Hostname() string {
+  return self.hostname
+}
+
+func (self CiscoMessage) Message() string {
+  return self.message
+}
+
+func (self CiscoMessage) Sequence() string {
+  return self.sequence
+}
+
+func (self CiscoMessage) MessageId() string {
+  return self.messageId
+}
+
+func (self CiscoMessage) FacilityMnemonic() string {
+  return self.facilityName
+}
+
+func (self CiscoMessage) Mnemonic() string {
+  return self.mnemonic
+}
+
+func (self CiscoMessage) Zone() string {
+  return self.zone
+}
+
+func (self CiscoMessage) Unsynchronized() bool {
+  return self.unsynchronized
+}
+
+func (self CiscoMessage) YearInferred() bool {
+  return self.yearInferred
+}
+
+func (self CiscoMessage) TimestampInfo() message.TimestampInfo {
+  return self.timestampInfo
+}
@@ -0,0 +1,122 @@
+/* sysloglint checks syslog messages against RFC 5424 and RFC 3164 and
+   prints every violation found, one message per line unless -octet-counting
+   is given :
+
+     sysloglint [-format auto|rfc5424|rfc3164] [-octet-counting] [file ...]
+
+   Violations are printed as file:line: violation, line being the line of
+   the file the message starts on.
+   Messages are read from stdin when no file is given. The exit status is 1
+   when violations were found, 2 when the input could not be read. */
+package main
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/framing"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
+)
+
+type validateFunc func(buff []byte) []syslogparser.Violation
+
+func main() {
+  format := flag.String("format", "auto", "format of the messages: auto, rfc5424 or rfc3164")
+  octetCounting := flag.Bool("octet-counting", false, "messages are octet counted instead of one per line")
+  flag.Parse()
+
+  var validate validateFunc
+  switch *format {
+  case "auto":
+    validate = validateAny
+  case "rfc5424":
+    validate = rfc5424.Validate
+  case "rfc3164":
+    validate = rfc3164.Validate
+  default:
+    fmt.Fprintf(os.Stderr, "Unknown format %q\n", *format)
+    os.Exit(2)
+  }
+
+  method := framing.NonTransparent
+  if *octetCounting {
+    method = framing.OctetCounting
+  }
+
+  files := flag.Args()
+  if len(files) == 0 {
+    files = []string{"-"}
+  }
+
+  found := false
+  for _, name := range files {
+    violations, err := lintFile(name, method, validate)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+      os.Exit(2)
+    }
+
+    found = found || violations
+  }
+
+  if found {
+    os.Exit(1)
+  }
+}
+
+// lintFile prints the violations of the messages in the file name, "-" being stdin
+func lintFile(name string, method framing.Method, validate validateFunc) (bool, error) {
+  var r io.Reader = os.Stdin
+  if name != "-" {
+    f, err := os.Open(name)
+    if err != nil {
+      return false, err
+    }
+    defer f.Close()
+    r = f
+  }
+
+  d := framing.NewDecoder(r, method)
+  found := false
+
+  for {
+    frame, err := d.Next()
+    if err == io.EOF {
+      return found, nil
+    }
+
+    if err == framing.ErrFrameTooLarge {
+      fmt.Printf("%s:%d: %s\n", name, d.Line(), err)
+      found = true
+      continue
+    }
+
+    if err != nil {
+      return found, err
+    }
+
+    for _, v := range validate(frame) {
+      fmt.Printf("%s:%d: %s\n", name, d.Line(), v)
+      found = true
+    }
+  }
+}
+
+/* validateAny checks messages whose PRI is followed by a VERSION and a SP
+   as RFC 5424, and all others as RFC 3164. */
+func validateAny(buff []byte) []syslogparser.Violation {
+  cursor := 0
+  _, err := syslogparser.ParsePriority(buff, &cursor, len(buff))
+  if err == nil {
+    for i := cursor; i < len(buff) && syslogparser.IsDigit(buff[i]); i++ {
+      if i+1 < len(buff) && buff[i+1] == ' ' {
+        return rfc5424.Validate(buff)
+      }
+    }
+  }
+
+  return rfc3164.Validate(buff)
+}
@@ -0,0 +1,270 @@
+/* syslogparse parses syslog messages, one per line unless -octet-counting is
+   given, and writes them as JSON Lines :
+
+     syslogparse [-format auto|rfc5424|rfc3164|cisco|junos] [-timezone Europe/Paris]
+                 [-year 2015] [-charset auto|latin1...] [-unparsable]
+                 [-fields f1,f2...] [file ...]
+
+   Each object holds the fields of Dump() along with "format", the format
+   the message was parsed as, and "year_inferred" for RFC 3164 and Cisco messages,
+   with "charset" when -charset is auto. Unparsable lines are reported on
+   stderr as file:line: error, line being the line of the file the message
+   starts on, or written with their "raw" content and "error" when -unparsable
+   is given.
+   Messages are read from stdin when no file is given. */
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "time"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/charset"
+  "github.com/scalingdata/syslogparser/cisco"
+  "github.com/scalingdata/syslogparser/framing"
+  "github.com/scalingdata/syslogparser/junos"
+  "github.com/scalingdata/syslogparser/multiparser"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
+)
+
+type options struct {
+  factory    syslogparser.ParserFactory
+  method     framing.Method
+  unparsable bool
+  // Output fields, all of them when empty
+  fields     []string
+}
+
+func main() {
+  format := flag.String("format", "auto", "format of the messages: auto, rfc5424, rfc3164, cisco or junos")
+  timezone := flag.String("timezone", "Local", "time zone of RFC 3164 and Cisco timestamps without one, eg. UTC or Europe/Paris")
+  year := flag.Int("year", 0, "year of RFC 3164 and Cisco timestamps without one, inferred from the current date when 0")
+  charsetName := flag.String("charset", "", "charset of RFC 3164 messages, eg. latin1, windows-1252 or shift_jis, auto to detect it")
+  unparsable := flag.Bool("unparsable", false, "write unparsable lines with their error instead of reporting them on stderr")
+  fields := flag.String("fields", "", "comma separated list of the fields to write, all of them when empty")
+  octetCounting := flag.Bool("octet-counting", false, "messages are octet counted instead of one per line")
+  flag.Parse()
+
+  loc, err := time.LoadLocation(*timezone)
+  if err != nil {
+    fatal(err)
+  }
+
+  var reference time.Time
+  if *year != 0 {
+    reference = referenceTime(*year, loc)
+  }
+
+  opts := options{
+    method:     framing.NonTransparent,
+    unparsable: *unparsable,
+  }
+
+  opts.factory, err = newParserFactory(*format, loc, reference, *charsetName)
+  if err != nil {
+    fatal(err)
+  }
+
+  if *octetCounting {
+    opts.method = framing.OctetCounting
+  }
+
+  if *fields != "" {
+    opts.fields = strings.Split(*fields, ",")
+  }
+
+  files := flag.Args()
+  if len(files) == 0 {
+    files = []string{"-"}
+  }
+
+  w := bufio.NewWriter(os.Stdout)
+  for _, name := range files {
+    if err := parseFile(name, w, opts); err != nil {
+      w.Flush()
+      fatal(fmt.Errorf("%s: %s", name, err))
+    }
+  }
+
+  if err := w.Flush(); err != nil {
+    fatal(err)
+  }
+}
+
+func fatal(err error) {
+  fmt.Fprintln(os.Stderr, err)
+  os.Exit(2)
+}
+
+func newParserFactory(format string, loc *time.Location, reference time.Time, charsetName string) (syslogparser.ParserFactory, error) {
+  var decoder syslogparser.CharsetDecoder
+  if charsetName != "" && charsetName != "auto" {
+    var err error
+    decoder, err = charset.Decoder(charsetName)
+    if err != nil {
+      return nil, fmt.Errorf("%s %q", err, charsetName)
+    }
+  }
+
+  new3164 := func(buff *[]byte) syslogparser.LogParser {
+    p := rfc3164.NewParser(buff)
+    p.Location = loc
+    p.ReferenceTime = reference
+    p.Decoder = decoder
+    p.DetectCharset = charsetName == "auto"
+    return p
+  }
+
+  new5424 := func(buff *[]byte) syslogparser.LogParser {
+    return rfc5424.NewParser(buff)
+  }
+
+  newCisco := func(buff *[]byte) syslogparser.LogParser {
+    p := cisco.NewParser(buff)
+    p.Location = loc
+    p.ReferenceTime = reference
+    return p
+  }
+
+  switch format {
+  case "auto":
+    opts := multiparser.Options{
+      multiparser.OPTION_LOCATION:       loc,
+      multiparser.OPTION_REFERENCE_TIME: reference,
+      multiparser.OPTION_DECODER:        decoder,
+      multiparser.OPTION_DETECT_CHARSET: charsetName == "auto",
+    }
+    return func(buff *[]byte) syslogparser.LogParser {
+      return multiparser.DefaultRegistry.NewParser(buff, opts)
+    }, nil
+  case "rfc3164":
+    return new3164, nil
+  case "rfc5424":
+    return new5424, nil
+  case "cisco":
+    return newCisco, nil
+  case "junos":
+    return func(buff *[]byte) syslogparser.LogParser {
+      p := junos.NewParser(buff)
+      p.BSD = new3164(buff).(*rfc3164.Parser)
+      return p
+    }, nil
+  }
+
+  return nil, fmt.Errorf("Unknown format %q", format)
+}
+
+/* referenceTime makes RFC 3164 timestamps without a year fall in year : the
+   parser picks the year closest to the reference time, the middle of year. */
+func referenceTime(year int, loc *time.Location) time.Time {
+  start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+  end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc)
+
+  return start.Add(end.Sub(start) / 2)
+}
+
+// parseFile writes the messages of the file name, "-" being stdin, to w
+func parseFile(name string, w io.Writer, opts options) error {
+  var r io.Reader = os.Stdin
+  if name != "-" {
+    f, err := os.Open(name)
+    if err != nil {
+      return err
+    }
+    defer f.Close()
+    r = f
+  }
+
+  d := framing.NewDecoder(r, opts.method)
+  enc := json.NewEncoder(w)
+
+  for {
+    frame, err := d.Next()
+    if err == io.EOF {
+      return nil
+    }
+
+    var parts syslogparser.LogParts
+    if err == framing.ErrFrameTooLarge {
+      parts = unparsableParts(nil, err)
+    } else if err != nil {
+      return err
+    } else {
+      parts = parse(frame, opts.factory)
+    }
+
+    if parseErr, failed := parts["error"]; failed && !opts.unparsable {
+      fmt.Fprintf(os.Stderr, "%s:%d: %s\n", name, d.Line(), parseErr)
+      continue
+    }
+
+    if err := enc.Encode(selectFields(parts, opts.fields)); err != nil {
+      return err
+    }
+  }
+}
+
+func parse(frame []byte, factory syslogparser.ParserFactory) syslogparser.LogParts {
+  p := factory(&frame)
+  if err := p.Parse(); err != nil {
+    return unparsableParts(frame, err)
+  }
+
+  parts := p.Dump()
+
+  m := p.Message()
+  if junosMsg, ok := m.(junos.IMessage); ok {
+    parts["format"] = "junos"
+    m = junosMsg.Syslog()
+  }
+
+  switch msg := m.(type) {
+  case cisco.IMessage:
+    parts["format"] = "cisco"
+    parts["year_inferred"] = msg.YearInferred()
+  case rfc5424.IMessage:
+    setDefault(parts, "format", "rfc5424")
+  case rfc3164.IMessage:
+    setDefault(parts, "format", "rfc3164")
+    parts["year_inferred"] = msg.YearInferred()
+    if msg.DetectedCharset() != "" {
+      parts["charset"] = msg.DetectedCharset()
+    }
+  }
+
+  return parts
+}
+
+func setDefault(parts syslogparser.LogParts, field string, value interface{}) {
+  if _, ok := parts[field]; !ok {
+    parts[field] = value
+  }
+}
+
+func unparsableParts(frame []byte, err error) syslogparser.LogParts {
+  return syslogparser.LogParts{
+    "raw":   string(frame),
+    "error": err.Error(),
+  }
+}
+
+func selectFields(parts syslogparser.LogParts, fields []string) syslogparser.LogParts {
+  if len(fields) == 0 {
+    return parts
+  }
+
+  selected := make(syslogparser.LogParts, len(fields))
+  for _, f := range fields {
+    if v, ok := parts[f]; ok {
+      selected[f] = v
+    }
+  }
+
+  return selected
+}
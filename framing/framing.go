@@ -0,0 +1,265 @@
+// Splits a syslog byte stream into frames as described in
+// https://tools.ietf.org/html/rfc6587#section-3.4
+
+package framing
+
+import (
+  "bufio"
+  "bytes"
+  "io"
+  "github.com/scalingdata/syslogparser"
+)
+
+type Method int
+
+const (
+  AutoDetect Method = iota
+  OctetCounting
+  NonTransparent
+)
+
+const (
+  DEFAULT_MAX_FRAME_SIZE = 64 * 1024
+
+  // MSG-LEN digits we accept before giving up on the header
+  maxMsgLenDigits = 10
+)
+
+var (
+  ErrFrameTooLarge  = &FramingError{"Frame exceeds the maximum frame size"}
+  ErrInvalidMsgLen  = &FramingError{"Invalid MSG-LEN in octet counted frame"}
+  ErrTruncatedFrame = &FramingError{"Stream ended in the middle of a frame"}
+  ErrUnknownFraming = &FramingError{"Unable to detect framing method"}
+)
+
+/* FramingError reports a broken stream, as opposed to
+   syslogparser.ParserError which reports a broken message. */
+type FramingError struct {
+  ErrorString string
+}
+
+func (err *FramingError) Error() string {
+  return err.ErrorString
+}
+
+type Decoder struct {
+  r            *bufio.Reader
+  method       Method
+  MaxFrameSize int
+  // LFs read so far, trailers and those inside frames
+  lfs          int64
+  // Line the last frame starts on
+  line         int64
+}
+
+/* NewDecoder reads frames from r. With AutoDetect the framing method is
+   chosen from the first byte of the stream and kept for its whole life :
+   octet counted frames start with a digit, non-transparent ones with '<'. */
+func NewDecoder(r io.Reader, method Method) *Decoder {
+  return &Decoder{
+    r:            bufio.NewReader(r),
+    method:       method,
+    MaxFrameSize: DEFAULT_MAX_FRAME_SIZE,
+  }
+}
+
+// Method returns the framing in use, AutoDetect until the first frame is read
+func (d *Decoder) Method() Method {
+  return d.method
+}
+
+/* Line returns the 1-based line of the stream the last frame returned, or
+   reported by ErrFrameTooLarge, starts on. Empty lines skipped between
+   frames and LFs inside octet counted frames are counted. */
+func (d *Decoder) Line() int64 {
+  return d.line
+}
+
+// readByte reads a byte of the stream, counting LFs
+func (d *Decoder) readByte() (byte, error) {
+  c, err := d.r.ReadByte()
+  if err == nil && c == '\n' {
+    d.lfs++
+  }
+
+  return c, err
+}
+
+/* Next returns the next frame, or io.EOF once the stream is exhausted.
+   ErrFrameTooLarge is recoverable : the oversized frame is skipped and the
+   following call returns the next one. Other errors leave the stream
+   unusable. */
+func (d *Decoder) Next() ([]byte, error) {
+  if d.method == AutoDetect {
+    if err := d.detect(); err != nil {
+      return nil, err
+    }
+  }
+
+  if d.method == OctetCounting {
+    return d.nextOctetCounted()
+  }
+
+  return d.nextNonTransparent()
+}
+
+func (d *Decoder) detect() error {
+  for {
+    b, err := d.r.Peek(1)
+    if err != nil {
+      return err
+    }
+
+    c := b[0]
+    switch {
+    case c >= '1' && c <= '9':
+      d.method = OctetCounting
+      return nil
+    case c == syslogparser.PRI_PART_START:
+      d.method = NonTransparent
+      return nil
+    case isTrailer(c):
+      // Leading empty lines tell nothing about the framing
+      d.readByte()
+    default:
+      return ErrUnknownFraming
+    }
+  }
+}
+
+// OCTET-COUNTING = MSG-LEN SP SYSLOG-MSG
+func (d *Decoder) nextOctetCounted() ([]byte, error) {
+  msgLen := 0
+  digits := 0
+
+  for {
+    c, err := d.readByte()
+    if err == io.EOF {
+      if digits == 0 {
+        return nil, io.EOF
+      }
+      return nil, ErrTruncatedFrame
+    }
+    if err != nil {
+      return nil, err
+    }
+
+    // Be liberal and skip trailers some senders add after octet counted frames
+    if digits == 0 && isTrailer(c) {
+      continue
+    }
+
+    if digits == 0 {
+      d.line = d.lfs + 1
+    }
+
+    if c == ' ' && digits > 0 {
+      break
+    }
+
+    if !syslogparser.IsDigit(c) || (digits == 0 && c == '0') || digits >= maxMsgLenDigits {
+      return nil, ErrInvalidMsgLen
+    }
+
+    msgLen = msgLen*10 + int(c-'0')
+    digits++
+  }
+
+  if msgLen > d.MaxFrameSize {
+    if err := d.discard(msgLen); err != nil {
+      return nil, ErrTruncatedFrame
+    }
+    return nil, ErrFrameTooLarge
+  }
+
+  frame := make([]byte, msgLen)
+  if _, err := io.ReadFull(d.r, frame); err != nil {
+    return nil, ErrTruncatedFrame
+  }
+  d.lfs += int64(bytes.Count(frame, []byte{'\n'}))
+
+  return frame, nil
+}
+
+// NON-TRANSPARENT-FRAMING = SYSLOG-MSG TRAILER, TRAILER being LF or NUL
+func (d *Decoder) nextNonTransparent() ([]byte, error) {
+  var frame []byte
+  tooLarge := false
+
+  for {
+    c, err := d.readByte()
+    if err == io.EOF {
+      // The last frame of a stream often lacks its trailer
+      if tooLarge {
+        return nil, ErrFrameTooLarge
+      }
+      if frame = trimCR(frame); len(frame) > 0 {
+        return frame, nil
+      }
+      return nil, io.EOF
+    }
+    if err != nil {
+      return nil, err
+    }
+
+    if isTrailer(c) {
+      if tooLarge {
+        return nil, ErrFrameTooLarge
+      }
+      if frame = trimCR(frame); len(frame) == 0 {
+        continue
+      }
+      return frame, nil
+    }
+
+    if tooLarge {
+      continue
+    }
+
+    if len(frame) == 0 {
+      d.line = d.lfs + 1
+    }
+
+    if len(frame) >= d.MaxFrameSize {
+      frame = nil
+      tooLarge = true
+      continue
+    }
+
+    frame = append(frame, c)
+  }
+}
+
+// discard skips n bytes of the stream, counting their LFs
+func (d *Decoder) discard(n int) error {
+  for n > 0 {
+    // Fills the buffer
+    if _, err := d.r.Peek(1); err != nil {
+      return err
+    }
+
+    b, _ := d.r.Peek(d.r.Buffered())
+    if len(b) > n {
+      b = b[:n]
+    }
+
+    d.lfs += int64(bytes.Count(b, []byte{'\n'}))
+    d.r.Discard(len(b))
+    n -= len(b)
+  }
+
+  return nil
+}
+
+func isTrailer(c byte) bool {
+  return c == '\n' || c == 0
+}
+
+// Senders using CRLF as trailer leave a CR behind
+func trimCR(frame []byte) []byte {
+  if len(frame) > 0 && frame[len(frame)-1] == '\r' {
+    return frame[:len(frame)-1]
+  }
+
+  return frame
+}
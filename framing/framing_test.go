@@ -0,0 +1,159 @@
+package framing
+
+import (
+  "io"
+  . "github.com/scalingdata/check"
+  "strconv"
+  "strings"
+  "testing"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FramingTestSuite struct {
+}
+
+var _ = Suite(&FramingTestSuite{})
+
+const (
+  msg3164 = "<34>Oct 11 22:14:15 mymachine su: 'su root' failed"
+  msg5424 = "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event"
+)
+
+func (s *FramingTestSuite) TestNext_OctetCounting(c *C) {
+  stream := octetCounted(msg3164) + octetCounted(msg5424)
+
+  d := NewDecoder(strings.NewReader(stream), AutoDetect)
+  s.assertFrames(c, d, []string{msg3164, msg5424})
+  c.Assert(d.Method(), Equals, OctetCounting)
+}
+
+func (s *FramingTestSuite) TestNext_OctetCountingWithNewlines(c *C) {
+  multiline := "<34>Oct 11 22:14:15 mymachine su: line1\nline2"
+  stream := octetCounted(multiline) + "\n" + octetCounted(msg3164)
+
+  d := NewDecoder(strings.NewReader(stream), OctetCounting)
+  s.assertFrames(c, d, []string{multiline, msg3164})
+}
+
+func (s *FramingTestSuite) TestNext_NonTransparent(c *C) {
+  stream := "\n" + msg3164 + "\r\n" + msg5424 + "\x00\n" + msg3164
+
+  d := NewDecoder(strings.NewReader(stream), AutoDetect)
+  s.assertFrames(c, d, []string{msg3164, msg5424, msg3164})
+  c.Assert(d.Method(), Equals, NonTransparent)
+}
+
+func (s *FramingTestSuite) TestNext_NonTransparentFrameTooLarge(c *C) {
+  stream := msg5424 + "\n" + msg3164 + "\n"
+
+  d := NewDecoder(strings.NewReader(stream), NonTransparent)
+  d.MaxFrameSize = len(msg3164)
+
+  _, err := d.Next()
+  c.Assert(err, Equals, ErrFrameTooLarge)
+
+  s.assertFrames(c, d, []string{msg3164})
+}
+
+func (s *FramingTestSuite) TestNext_OctetCountingFrameTooLarge(c *C) {
+  stream := octetCounted(msg5424) + octetCounted(msg3164)
+
+  d := NewDecoder(strings.NewReader(stream), OctetCounting)
+  d.MaxFrameSize = len(msg3164)
+
+  _, err := d.Next()
+  c.Assert(err, Equals, ErrFrameTooLarge)
+
+  s.assertFrames(c, d, []string{msg3164})
+}
+
+func (s *FramingTestSuite) TestNext_InvalidMsgLen(c *C) {
+  fixtures := []string{
+    "0 " + msg3164,
+    "5a " + msg3164,
+    "12345678901 " + msg3164,
+  }
+
+  for _, f := range fixtures {
+    d := NewDecoder(strings.NewReader(f), OctetCounting)
+    _, err := d.Next()
+    c.Assert(err, Equals, ErrInvalidMsgLen)
+  }
+}
+
+func (s *FramingTestSuite) TestNext_Truncated(c *C) {
+  d := NewDecoder(strings.NewReader("100 "+msg3164), OctetCounting)
+  _, err := d.Next()
+  c.Assert(err, Equals, ErrTruncatedFrame)
+
+  d = NewDecoder(strings.NewReader("100"), OctetCounting)
+  _, err = d.Next()
+  c.Assert(err, Equals, ErrTruncatedFrame)
+}
+
+func (s *FramingTestSuite) TestNext_UnknownFraming(c *C) {
+  d := NewDecoder(strings.NewReader("FOO BAR"), AutoDetect)
+  _, err := d.Next()
+  c.Assert(err, Equals, ErrUnknownFraming)
+}
+
+func (s *FramingTestSuite) TestNext_Empty(c *C) {
+  d := NewDecoder(strings.NewReader(""), AutoDetect)
+  _, err := d.Next()
+  c.Assert(err, Equals, io.EOF)
+}
+
+func (s *FramingTestSuite) TestLine_NonTransparent(c *C) {
+  stream := "\n" + msg3164 + "\r\n\n\n" + msg5424 + "\n" + msg5424 + "\n" + msg3164
+  d := NewDecoder(strings.NewReader(stream), AutoDetect)
+  // With the CR
+  d.MaxFrameSize = len(msg3164) + 1
+
+  _, err := d.Next()
+  c.Assert(err, IsNil)
+  c.Assert(d.Line(), Equals, int64(2))
+
+  _, err = d.Next()
+  c.Assert(err, Equals, ErrFrameTooLarge)
+  c.Assert(d.Line(), Equals, int64(5))
+
+  _, err = d.Next()
+  c.Assert(err, Equals, ErrFrameTooLarge)
+  c.Assert(d.Line(), Equals, int64(6))
+
+  _, err = d.Next()
+  c.Assert(err, IsNil)
+  c.Assert(d.Line(), Equals, int64(7))
+}
+
+func (s *FramingTestSuite) TestLine_OctetCounting(c *C) {
+  multiline := "<34>Oct 11 22:14:15 mymachine su: line1\nline2"
+  stream := octetCounted(multiline) + "\n" + octetCounted(msg5424) + "\n\n" + octetCounted(multiline) + octetCounted(msg3164)
+  d := NewDecoder(strings.NewReader(stream), OctetCounting)
+  d.MaxFrameSize = len(msg3164)
+
+  lines := []int64{1, 3, 5, 6}
+  for _, line := range lines {
+    d.Next()
+    c.Assert(d.Line(), Equals, line)
+  }
+}
+
+// -------------
+
+func octetCounted(msg string) string {
+  return strconv.Itoa(len(msg)) + " " + msg
+}
+
+func (s *FramingTestSuite) assertFrames(c *C, d *Decoder, expected []string) {
+  for _, e := range expected {
+    frame, err := d.Next()
+    c.Assert(err, IsNil)
+    c.Assert(string(frame), Equals, e)
+  }
+
+  _, err := d.Next()
+  c.Assert(err, Equals, io.EOF)
+}
@@ -0,0 +1,17 @@
+// Conversions sharing memory instead of copying it
+
+package zerocopy
+
+import (
+  "unsafe"
+)
+
+/* String returns a string backed by b without copying it. The string is
+   only valid as long as b is not modified. */
+func String(b []byte) string {
+  if len(b) == 0 {
+    return ""
+  }
+
+  return *(*string)(unsafe.Pointer(&b))
+}
@@ -0,0 +1,431 @@
+// Decoder of the syslog messages of Juniper Junos devices
+
+package junos
+
+import (
+  "bytes"
+  "github.com/scalingdata/syslogparser"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "net"
+  "strconv"
+  "strings"
+)
+
+// Reported in syslogparser.PositionError
+const FORMAT = "Junos"
+
+const (
+  // SD-ID of the structured data of Junos, followed by the platform OID
+  SD_ID = "junos@2636"
+  // Prefix of the SD-IDs ending with a platform number, eg. junos@2636.1.1.1.2.26
+  PLATFORM_SD_ID_PREFIX = SD_ID + ".1.1.1.2."
+  // Bytes Detect looks for the junos@2636 SD-ELEMENT or the event tag in
+  MAX_HEADER_LEN = 256
+)
+
+var (
+  ErrNoJunosSD        = &syslogparser.ParserError{"No junos@2636 SD-ELEMENT"}
+  ErrEventTagNotFound = &syslogparser.ParserError{"No event tag found"}
+)
+
+/* Platforms names the platform numbers ending junos@2636 SD-IDs, from the
+   jnxProductLine OIDs of the JUNIPER-CHASSIS-DEFINES-MIB. Add the platforms
+   missing from it at init time. */
+var Platforms = map[int]string{
+  1:  "m40",
+  2:  "m20",
+  3:  "m160",
+  4:  "m10",
+  5:  "m5",
+  6:  "t640",
+  7:  "t320",
+  8:  "m40e",
+  9:  "m320",
+  10: "m7i",
+  11: "m10i",
+  18: "m120",
+  21: "mx960",
+  25: "mx480",
+  26: "srx5800",
+  27: "t1600",
+  28: "srx5600",
+  29: "mx240",
+  30: "ex3200",
+  31: "ex4200",
+  32: "ex8208",
+  33: "ex8216",
+  34: "srx3600",
+  35: "srx3400",
+  36: "srx210",
+  39: "srx240",
+  40: "srx650",
+  41: "srx100",
+  43: "ex2200",
+  44: "ex4500",
+  57: "mx80",
+}
+
+type ParamKind int
+
+const (
+  STRING_PARAM ParamKind = iota
+  // Decimal integer, int64 in Fields
+  INT_PARAM
+  // IPv4 or IPv6 address, net.IP in Fields
+  ADDRESS_PARAM
+)
+
+/* ParamKinds gives the kind of junos@2636 parameters by name. Those missing
+   from it are addresses when their name ends with -address, integers when
+   it ends with -port, and strings otherwise. */
+var ParamKinds = map[string]ParamKind{
+  "protocol-id":         INT_PARAM,
+  "icmp-type":           INT_PARAM,
+  "session-id-32":       INT_PARAM,
+  "packets-from-client": INT_PARAM,
+  "bytes-from-client":   INT_PARAM,
+  "packets-from-server": INT_PARAM,
+  "bytes-from-server":   INT_PARAM,
+  "elapsed-time":        INT_PARAM,
+  "error-code":          INT_PARAM,
+}
+
+// Kind gives the kind of the parameter name, see ParamKinds
+func Kind(name string) ParamKind {
+  if kind, ok := ParamKinds[name]; ok {
+    return kind
+  }
+
+  switch {
+  case strings.HasSuffix(name, "-address"):
+    return ADDRESS_PARAM
+  case strings.HasSuffix(name, "-port"):
+    return INT_PARAM
+  }
+
+  return STRING_PARAM
+}
+
+/* Event is what Junos tells of a message on top of syslog : its event tag,
+   and the parameters of its junos@2636 SD-ELEMENT in structured mode or
+   the explicit priority in BSD mode. */
+type Event struct {
+  // Event tag, eg. UI_COMMIT, to route messages on
+  Tag string
+  // Number of the platform ending the SD-ID, 0 when not sent, see Platforms
+  Platform int
+  Params []rfc5424.SDParam
+  // Junos facility of explicit-priority BSD messages, eg. INTERACT, empty otherwise
+  ExplicitFacility string
+  // Severity of explicit-priority BSD messages, -1 otherwise
+  ExplicitSeverity int
+}
+
+// PlatformName gives the name of the Platform, eg. srx5800, empty when unknown
+func (e Event) PlatformName() string {
+  return Platforms[e.Platform]
+}
+
+// Param gives the value of the parameter name, and tells if it was sent
+func (e Event) Param(name string) (string, bool) {
+  for _, param := range e.Params {
+    if param.Name == name {
+      return param.Value, true
+    }
+  }
+
+  return "", false
+}
+
+// Int gives the parameter name as an integer
+func (e Event) Int(name string) (int64, bool) {
+  value, ok := e.Param(name)
+  if !ok {
+    return 0, false
+  }
+
+  i, err := strconv.ParseInt(value, 10, 64)
+  return i, err == nil
+}
+
+// Address gives the parameter name as an IP address
+func (e Event) Address(name string) (net.IP, bool) {
+  value, ok := e.Param(name)
+  if !ok {
+    return nil, false
+  }
+
+  ip := net.ParseIP(value)
+  return ip, ip != nil
+}
+
+/* Fields gives the parameters by name, typed after their Kind : values
+   not of their kind, eg. "N/A" for an address, are kept as strings. */
+func (e Event) Fields() map[string]interface{} {
+  fields := make(map[string]interface{}, len(e.Params))
+
+  for _, param := range e.Params {
+    fields[param.Name] = param.Value
+
+    switch Kind(param.Name) {
+    case INT_PARAM:
+      if i, err := strconv.ParseInt(param.Value, 10, 64); err == nil {
+        fields[param.Name] = i
+      }
+    case ADDRESS_PARAM:
+      if ip := net.ParseIP(param.Value); ip != nil {
+        fields[param.Name] = ip
+      }
+    }
+  }
+
+  return fields
+}
+
+/* Parser reads the messages of Junos in structured mode, RFC 5424 messages
+   whose MSGID is the event tag :
+
+     <165>1 2024-03-01T12:00:00.123Z srx1 mgd 3046 UI_COMMIT [junos@2636.1.1.1.2.26 username="admin" command="commit"] User 'admin' requested 'commit' operation
+
+   and in BSD mode, RFC 3164 messages whose content starts with the event
+   tag, preceded by the Junos facility and severity with explicit-priority :
+
+     <189>Mar  1 12:00:00 srx1 mgd[3046]: %INTERACT-6-UI_COMMIT: User 'admin' requested 'commit' operation
+
+   The parsers of each mode can be set up, eg. the Location of BSD. */
+type Parser struct {
+  buff       []byte
+  Structured *rfc5424.Parser
+  BSD        *rfc3164.Parser
+  // Parser of the mode of the message
+  parsed     syslogparser.LogParser
+  event      Event
+  // MSG without the event tag in BSD mode
+  text       string
+  parseSuccessful bool
+}
+
+func NewParser(buff *[]byte) *Parser {
+  return &Parser{
+    buff:       *buff,
+    Structured: rfc5424.NewParser(buff),
+    BSD:        rfc3164.NewParser(buff),
+  }
+}
+
+// Reset prepares the parser for a new message, keeping its settings
+func (p *Parser) Reset(buff *[]byte) {
+  p.buff = *buff
+  p.Structured.Reset(buff)
+  p.BSD.Reset(buff)
+  p.parsed = nil
+  p.event = Event{}
+  p.text = ""
+  p.parseSuccessful = false
+}
+
+// SetZeroCopy sets the zero copy mode of the parsers of both modes
+func (p *Parser) SetZeroCopy(zeroCopy bool) {
+  p.Structured.SetZeroCopy(zeroCopy)
+  p.BSD.SetZeroCopy(zeroCopy)
+}
+
+var sdElementStart = []byte("[" + SD_ID)
+
+/* Detect tells how likely buff is a Junos message : CERTAIN for RFC 5424
+   messages with a junos@2636 SD-ELEMENT, LIKELY for RFC 3164 messages
+   whose content starts with an event tag. */
+func Detect(buff []byte) syslogparser.Confidence {
+  head := buff
+  if len(head) > MAX_HEADER_LEN {
+    head = head[:MAX_HEADER_LEN]
+  }
+
+  if rfc5424.Detect(buff) == syslogparser.CERTAIN {
+    if bytes.Contains(head, sdElementStart) {
+      return syslogparser.CERTAIN
+    }
+
+    return syslogparser.NO_MATCH
+  }
+
+  if confidence := rfc3164.Detect(buff); confidence < syslogparser.LIKELY {
+    return confidence
+  }
+
+  // The content follows the colon ending the TAG, those of times being followed by digits
+  i := bytes.Index(head, []byte(": "))
+  if i < 0 {
+    return syslogparser.UNLIKELY
+  }
+
+  if _, ok := splitEventTag(head[i+2:]); ok {
+    return syslogparser.LIKELY
+  }
+
+  return syslogparser.UNLIKELY
+}
+
+func (p *Parser) Parse() error {
+  if rfc5424.Detect(p.buff) == syslogparser.CERTAIN {
+    return p.parseStructured()
+  }
+
+  return p.parseBSD()
+}
+
+func (p *Parser) parseStructured() error {
+  if err := p.Structured.Parse(); err != nil {
+    return err
+  }
+
+  msg, ok := p.Structured.Message().(rfc5424.IMessage)
+  if !ok {
+    // Handed over to a parser of another version
+    return syslogparser.NewPositionError(FORMAT, syslogparser.FIELD_VERSION, 0, syslogparser.ErrVersionUnsupported)
+  }
+
+  for _, element := range msg.SDElements() {
+    if element.ID != SD_ID && !strings.HasPrefix(element.ID, SD_ID+".") {
+      continue
+    }
+
+    p.event = Event{
+      Tag:              msg.MsgId(),
+      Platform:         platform(element.ID),
+      Params:           element.Params,
+      ExplicitSeverity: -1,
+    }
+    p.text = msg.Message()
+    p.parsed = p.Structured
+    p.parseSuccessful = true
+
+    return nil
+  }
+
+  offset := bytes.Index(p.buff, []byte(msg.StructuredData()))
+  return syslogparser.NewPositionError(FORMAT, syslogparser.FIELD_SD, offset, ErrNoJunosSD)
+}
+
+func (p *Parser) parseBSD() error {
+  if err := p.BSD.Parse(); err != nil {
+    return err
+  }
+
+  content := p.BSD.Message().Message()
+  parts, ok := splitEventTag([]byte(content))
+  if !ok {
+    offset := len(p.buff) - len(content)
+    return syslogparser.NewPositionError(FORMAT, syslogparser.FIELD_MSG, offset, ErrEventTagNotFound)
+  }
+
+  p.event = Event{
+    Tag:              content[parts.tag:parts.tagEnd],
+    ExplicitSeverity: parts.severity,
+  }
+  if parts.facilityEnd > 0 {
+    // Skip the %
+    p.event.ExplicitFacility = content[1:parts.facilityEnd]
+  }
+  p.text = strings.TrimLeft(content[parts.tagEnd+1:], " ")
+  p.parsed = p.BSD
+  p.parseSuccessful = true
+
+  return nil
+}
+
+// Dump gives the fields of the message along with the event tag, platform and typed parameters
+func (p *Parser) Dump() syslogparser.LogParts {
+  if !p.parseSuccessful {
+    return nil
+  }
+
+  parts := p.parsed.Dump()
+  parts["event_tag"] = p.event.Tag
+  parts["platform"] = p.event.PlatformName()
+  parts["junos_params"] = p.event.Fields()
+  if p.event.ExplicitFacility != "" {
+    parts["explicit_facility"] = p.event.ExplicitFacility
+    parts["explicit_severity"] = p.event.ExplicitSeverity
+  }
+
+  return parts
+}
+
+func (p *Parser) Message() message.IMessage {
+  if !p.parseSuccessful {
+    return message.NewUnparsableMessage(&p.buff)
+  }
+
+  return &JunosMessage{
+    IMessage: p.parsed.Message(),
+    event:    p.event,
+    text:     p.text,
+  }
+}
+
+// platform gives the number ending the SD-ID id, 0 if it has none
+func platform(id string) int {
+  if !strings.HasPrefix(id, PLATFORM_SD_ID_PREFIX) {
+    return 0
+  }
+
+  n, err := strconv.Atoi(id[len(PLATFORM_SD_ID_PREFIX):])
+  if err != nil {
+    return 0
+  }
+
+  return n
+}
+
+// Positions of the parts of an event tag, [%FACILITY-SEVERITY-]TAG:
+type eventTagParts struct {
+  // End of the %FACILITY, 0 without explicit priority
+  facilityEnd int
+  severity    int
+  tag         int
+  tagEnd      int
+}
+
+/* splitEventTag finds the event tag starting b. Tags are upper case words
+   of at least two parts, eg. UI_COMMIT, followed by a colon. */
+func splitEventTag(b []byte) (eventTagParts, bool) {
+  parts := eventTagParts{severity: -1}
+
+  if len(b) > 0 && b[0] == '%' {
+    i := 1
+    for i < len(b) && b[i] >= 'A' && b[i] <= 'Z' {
+      i++
+    }
+
+    if i == 1 || i+3 > len(b) || b[i] != '-' || b[i+1] < '0' || b[i+1] > '7' || b[i+2] != '-' {
+      return parts, false
+    }
+
+    parts.facilityEnd = i
+    parts.severity = int(b[i+1] - '0')
+    parts.tag = i + 3
+  }
+
+  underscore := false
+  i := parts.tag
+  for ; i < len(b) && b[i] != ':'; i++ {
+    c := b[i]
+    switch {
+    case c == '_' && i > parts.tag:
+      underscore = true
+    case c >= 'A' && c <= 'Z', syslogparser.IsDigit(c) && i > parts.tag:
+    default:
+      return parts, false
+    }
+  }
+
+  if i >= len(b) || !underscore || b[i-1] == '_' {
+    return parts, false
+  }
+
+  parts.tagEnd = i
+  return parts, true
+}
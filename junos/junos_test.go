@@ -0,0 +1,232 @@
+package junos
+
+import (
+  "errors"
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "net"
+  "testing"
+  "time"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type JunosTestSuite struct {
+}
+
+var _ = Suite(&JunosTestSuite{})
+
+const (
+  structuredMsg = `<165>1 2024-03-01T12:00:00.123Z srx1 mgd 3046 UI_COMMIT [junos@2636.1.1.1.2.26 username="admin" command="commit"] User 'admin' requested 'commit' operation`
+  flowMsg       = `<14>1 2024-03-01T12:00:00.123Z srx1 RT_FLOW - RT_FLOW_SESSION_CREATE [junos@2636.1.1.1.2.26 source-address="10.0.0.1" source-port="51234" destination-address="2001:db8::1" destination-port="443" protocol-id="6" nat-source-address="N/A" session-id-32="8123"] session created`
+  bsdMsg        = `<189>Mar  1 12:00:00 srx1 mgd[3046]: UI_COMMIT: User 'admin' requested 'commit' operation`
+  explicitMsg   = `<189>Mar  1 12:00:00 srx1 mgd[3046]: %INTERACT-6-UI_CMDLINE_READ_LINE: User 'admin', command 'show version'`
+)
+
+func newTestParser(buff string) *Parser {
+  b := []byte(buff)
+  p := NewParser(&b)
+  p.BSD.Location = time.UTC
+  p.BSD.ReferenceTime = time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+  return p
+}
+
+func (s *JunosTestSuite) TestParser_Structured(c *C) {
+  p := newTestParser(structuredMsg)
+  c.Assert(p.Parse(), IsNil)
+
+  msg, ok := p.Message().(IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert(msg.EventTag(), Equals, "UI_COMMIT")
+  c.Assert(msg.Message(), Equals, "User 'admin' requested 'commit' operation")
+  c.Assert(msg.Hostname(), Equals, "srx1")
+  c.Assert(msg.Severity(), Equals, message.Notice)
+
+  event := msg.Event()
+  c.Assert(event.Platform, Equals, 26)
+  c.Assert(event.PlatformName(), Equals, "srx5800")
+  c.Assert(event.ExplicitSeverity, Equals, -1)
+
+  username, ok := event.Param("username")
+  c.Assert(ok, Equals, true)
+  c.Assert(username, Equals, "admin")
+
+  _, ok = msg.Syslog().(rfc5424.IMessage)
+  c.Assert(ok, Equals, true)
+
+  parts := p.Dump()
+  c.Assert(parts["event_tag"], Equals, "UI_COMMIT")
+  c.Assert(parts["platform"], Equals, "srx5800")
+  c.Assert(parts["msg_id"], Equals, "UI_COMMIT")
+  c.Assert(parts["junos_params"], DeepEquals, map[string]interface{}{
+    "username": "admin",
+    "command":  "commit",
+  })
+}
+
+func (s *JunosTestSuite) TestEvent_Typed(c *C) {
+  p := newTestParser(flowMsg)
+  c.Assert(p.Parse(), IsNil)
+  event := p.Message().(IMessage).Event()
+
+  port, ok := event.Int("destination-port")
+  c.Assert(ok, Equals, true)
+  c.Assert(port, Equals, int64(443))
+
+  addr, ok := event.Address("source-address")
+  c.Assert(ok, Equals, true)
+  c.Assert(addr.Equal(net.ParseIP("10.0.0.1")), Equals, true)
+
+  _, ok = event.Address("nat-source-address")
+  c.Assert(ok, Equals, false)
+
+  _, ok = event.Int("missing")
+  c.Assert(ok, Equals, false)
+
+  c.Assert(event.Fields(), DeepEquals, map[string]interface{}{
+    "source-address":      net.ParseIP("10.0.0.1"),
+    "source-port":         int64(51234),
+    "destination-address": net.ParseIP("2001:db8::1"),
+    "destination-port":    int64(443),
+    "protocol-id":         int64(6),
+    "nat-source-address":  "N/A",
+    "session-id-32":       int64(8123),
+  })
+}
+
+func (s *JunosTestSuite) TestParser_BSD(c *C) {
+  p := newTestParser(bsdMsg)
+  c.Assert(p.Parse(), IsNil)
+
+  msg := p.Message().(IMessage)
+  c.Assert(msg.EventTag(), Equals, "UI_COMMIT")
+  c.Assert(msg.Message(), Equals, "User 'admin' requested 'commit' operation")
+  c.Assert(msg.Process(), Equals, "mgd")
+  c.Assert(msg.TimeStamp(), Equals, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+
+  event := msg.Event()
+  c.Assert(event.Platform, Equals, 0)
+  c.Assert(event.PlatformName(), Equals, "")
+  c.Assert(event.ExplicitFacility, Equals, "")
+  c.Assert(event.ExplicitSeverity, Equals, -1)
+
+  _, ok := msg.Syslog().(rfc3164.IMessage)
+  c.Assert(ok, Equals, true)
+}
+
+func (s *JunosTestSuite) TestParser_ExplicitPriority(c *C) {
+  p := newTestParser(explicitMsg)
+  c.Assert(p.Parse(), IsNil)
+
+  msg := p.Message().(IMessage)
+  c.Assert(msg.EventTag(), Equals, "UI_CMDLINE_READ_LINE")
+  c.Assert(msg.Message(), Equals, "User 'admin', command 'show version'")
+  c.Assert(msg.Event().ExplicitFacility, Equals, "INTERACT")
+  c.Assert(msg.Event().ExplicitSeverity, Equals, 6)
+
+  parts := p.Dump()
+  c.Assert(parts["explicit_facility"], Equals, "INTERACT")
+  c.Assert(parts["explicit_severity"], Equals, 6)
+}
+
+func (s *JunosTestSuite) TestParser_Invalid(c *C) {
+  fixtures := []struct {
+    buff   string
+    format string
+    field  string
+    offset int
+    err    error
+  }{
+    {`<165>1 2024-03-01T12:00:00Z srx1 mgd 3046 UI_COMMIT [origin ip="10.0.0.1"] committed`, FORMAT, syslogparser.FIELD_SD, 52, ErrNoJunosSD},
+    {`<34>Oct 11 22:14:15 mymachine su: 'su root' failed`, FORMAT, syslogparser.FIELD_MSG, 34, ErrEventTagNotFound},
+    {`<34>Oct 11 22:14:15 mymachine su: _UI: failed`, FORMAT, syslogparser.FIELD_MSG, 34, ErrEventTagNotFound},
+    {`<34>Oct 11 22:14:15 mymachine su: %INTERACT-9-UI_COMMIT: failed`, FORMAT, syslogparser.FIELD_MSG, 34, ErrEventTagNotFound},
+    {`<165>1 2024-03-01T12:00:00Z`, rfc5424.FORMAT, syslogparser.FIELD_HOSTNAME, 28, syslogparser.ErrEOL},
+  }
+
+  for _, f := range fixtures {
+    p := newTestParser(f.buff)
+    err := p.Parse()
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(err, &parseErr), Equals, true, Commentf(f.buff))
+    c.Assert(parseErr.Format, Equals, f.format, Commentf(f.buff))
+    c.Assert(parseErr.Field, Equals, f.field, Commentf(f.buff))
+    c.Assert(parseErr.Offset, Equals, f.offset, Commentf(f.buff))
+    c.Assert(errors.Is(err, f.err), Equals, true, Commentf(f.buff))
+
+    _, unparsable := p.Message().(*message.UnparsableMessage)
+    c.Assert(unparsable, Equals, true)
+    c.Assert(p.Dump(), IsNil)
+  }
+}
+
+func (s *JunosTestSuite) TestParser_Truncated(c *C) {
+  fixtures := []struct {
+    buff   string
+    format string
+    err    error
+  }{
+    // Too short to tell the structured mode
+    {`<34>1 2003-10-11`, rfc3164.FORMAT, syslogparser.ErrTimestampUnknownFormat},
+    {`<34>1 2003-10-11T22:14:15`, rfc5424.FORMAT, syslogparser.ErrTimestampUnknownFormat},
+    {`<34>Oct 11 22:14:15 mymachine su[`, FORMAT, ErrEventTagNotFound},
+    {`<189>Mar  1 12:00:00 srx1 mgd[3046]`, FORMAT, ErrEventTagNotFound},
+  }
+
+  for _, f := range fixtures {
+    // Without spare capacity, reading past the end panics
+    buff := []byte(f.buff)
+    buff = buff[:len(buff):len(buff)]
+    p := NewParser(&buff)
+    err := p.Parse()
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(err, &parseErr), Equals, true, Commentf(f.buff))
+    c.Assert(parseErr.Format, Equals, f.format, Commentf(f.buff))
+    c.Assert(errors.Is(err, f.err), Equals, true, Commentf("%s: %v", f.buff, err))
+  }
+
+  // Every prefix of the messages of both modes
+  for _, msg := range []string{structuredMsg, explicitMsg} {
+    for i := range msg {
+      buff := []byte(msg[:i])
+      buff = buff[:i:i]
+      NewParser(&buff).Parse()
+    }
+  }
+}
+
+func (s *JunosTestSuite) TestDetect(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected syslogparser.Confidence
+  }{
+    {structuredMsg, syslogparser.CERTAIN},
+    {flowMsg, syslogparser.CERTAIN},
+    {bsdMsg, syslogparser.LIKELY},
+    {explicitMsg, syslogparser.LIKELY},
+    {`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event`, syslogparser.NO_MATCH},
+    {`<34>Oct 11 22:14:15 mymachine su: 'su root' failed`, syslogparser.UNLIKELY},
+    {`FOO BAR BAZ`, syslogparser.NO_MATCH},
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Detect([]byte(f.buff)), Equals, f.expected, Commentf(f.buff))
+  }
+}
+
+func (s *JunosTestSuite) TestReset(c *C) {
+  p := newTestParser(structuredMsg)
+  c.Assert(p.Parse(), IsNil)
+
+  buff := []byte(bsdMsg)
+  p.Reset(&buff)
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().(IMessage).Event().Params, HasLen, 0)
+  c.Assert(p.Dump()["platform"], Equals, "")
+}
@@ -0,0 +1,41 @@
+package junos
+
+import (
+  message "github.com/scalingdata/syslogparser/message"
+)
+
+/* IMessage exposes the Junos event on top of the message.IMessage of its
+   mode, type assert Syslog() to an rfc5424.IMessage or rfc3164.IMessage to
+   get to the fields of the mode. */
+type IMessage interface {
+  message.IMessage
+  // Event tag, eg. UI_COMMIT
+  EventTag() string
+  Event() Event
+  // The message as parsed in its mode
+  Syslog() message.IMessage
+}
+
+/* JunosMessage is the message of its mode, with the MSG left of the event
+   tag in BSD mode. */
+type JunosMessage struct {
+  message.IMessage
+  event Event
+  text  string
+}
+
+func (self JunosMessage) Message() string {
+  return self.text
+}
+
+func (self JunosMessage) EventTag() string {
+  return self.event.Tag
+}
+
+func (self JunosMessage) Event() Event {
+  return self.event
+}
+
+func (self JunosMessage) Syslog() message.IMessage {
+  return self.IMessage
+}
@@ -20,6 +20,23 @@ type IMessage interface {
 }
 
 
+/* TimestampInfo tells how the timestamp of a message was sent, TimeStamp()
+   may have been converted to another time zone. */
+type TimestampInfo struct {
+  // TIMESTAMP as found in the message
+  Raw string
+  // Number of digits of the fraction of second
+  Precision int
+  // Offset of the sender's time zone in seconds east of UTC, the one the
+  // parser assumed when the timestamp has none
+  Offset int
+  // The message has no timestamp, TimeStamp() is then zero or the receive time
+  Absent bool
+  // The timestamp is a leap second, see the parser for how it was normalized
+  LeapSecond bool
+}
+
+
 type UnparsableMessage struct {
   rawMsg *[]byte
   ts time.Time
@@ -0,0 +1,165 @@
+package multiparser
+
+import (
+  "container/list"
+  "sync"
+  "time"
+)
+
+/* FormatCache remembers the format the last message of each source, a peer
+   address or a hostname, was parsed as, for Parsers to try it first : a
+   sender keeps sending the same dialect. It is safe for concurrent use, so
+   that the parsers of all the workers of a server can share it. The zero
+   value is an empty cache, unbounded and without expiry. */
+type FormatCache struct {
+  // Sources remembered at most, the least recently used ones are evicted first, unbounded when 0
+  Size int
+  // Time a format is remembered after the last message parsed as it, forever when 0
+  TTL time.Duration
+  // Gives the time entries expire against, time.Now when nil
+  TimeFunction func() time.Time
+
+  mutex   sync.Mutex
+  entries map[string]*list.Element
+  // Of *cacheEntry, most recently used first
+  lru     *list.List
+  stats   CacheStats
+}
+
+type cacheEntry struct {
+  source  string
+  format  string
+  expires time.Time
+}
+
+/* CacheStats counts the messages of known sources parsed as their cached
+   format, the hits, and the others, the misses. */
+type CacheStats struct {
+  Hits      uint64
+  Misses    uint64
+  Evictions uint64
+}
+
+// HitRate is the fraction of the messages that were hits, 0 before any message
+func (self CacheStats) HitRate() float64 {
+  total := self.Hits + self.Misses
+  if total == 0 {
+    return 0
+  }
+
+  return float64(self.Hits) / float64(total)
+}
+
+func NewFormatCache(size int, ttl time.Duration) *FormatCache {
+  return &FormatCache{
+    Size:    size,
+    TTL:     ttl,
+    entries: make(map[string]*list.Element),
+    lru:     list.New(),
+  }
+}
+
+/* Get gives the format cached for source, if it has not expired, and makes
+   source the most recently used. */
+func (self *FormatCache) Get(source string) (string, bool) {
+  self.mutex.Lock()
+  defer self.mutex.Unlock()
+
+  e, ok := self.entries[source]
+  if !ok {
+    return "", false
+  }
+
+  entry := e.Value.(*cacheEntry)
+  if self.TTL > 0 && !self.now().Before(entry.expires) {
+    self.remove(e)
+    return "", false
+  }
+
+  self.lru.MoveToFront(e)
+  return entry.format, true
+}
+
+// Put caches format for source, evicting the least recently used source when full
+func (self *FormatCache) Put(source string, format string) {
+  self.mutex.Lock()
+  defer self.mutex.Unlock()
+
+  if e, ok := self.entries[source]; ok {
+    entry := e.Value.(*cacheEntry)
+    entry.format = format
+    self.renew(e)
+    return
+  }
+
+  if nil == self.entries {
+    self.entries = make(map[string]*list.Element)
+    self.lru = list.New()
+  }
+
+  if self.Size > 0 && self.lru.Len() >= self.Size {
+    self.remove(self.lru.Back())
+    self.stats.Evictions++
+  }
+
+  e := self.lru.PushFront(&cacheEntry{source: source, format: format})
+  self.entries[source] = e
+  self.renew(e)
+}
+
+// Len gives the number of sources cached, expired ones included until they are looked up
+func (self *FormatCache) Len() int {
+  self.mutex.Lock()
+  defer self.mutex.Unlock()
+
+  return len(self.entries)
+}
+
+func (self *FormatCache) Stats() CacheStats {
+  self.mutex.Lock()
+  defer self.mutex.Unlock()
+
+  return self.stats
+}
+
+/* hit records a message of source parsed as its cached format, renewing
+   the entry as Put would. */
+func (self *FormatCache) hit(source string) {
+  self.mutex.Lock()
+  defer self.mutex.Unlock()
+
+  self.stats.Hits++
+  if e, ok := self.entries[source]; ok {
+    self.renew(e)
+  }
+}
+
+// miss records a message of a source not parsed as a cached format
+func (self *FormatCache) miss() {
+  self.mutex.Lock()
+  defer self.mutex.Unlock()
+
+  self.stats.Misses++
+}
+
+// renew makes the entry the most recently used and restarts its TTL
+func (self *FormatCache) renew(e *list.Element) {
+  if self.TTL > 0 {
+    e.Value.(*cacheEntry).expires = self.now().Add(self.TTL)
+  }
+
+  self.lru.MoveToFront(e)
+}
+
+func (self *FormatCache) remove(e *list.Element) {
+  self.lru.Remove(e)
+  delete(self.entries, e.Value.(*cacheEntry).source)
+}
+
+func (self *FormatCache) now() time.Time {
+  if self.TimeFunction != nil {
+    return self.TimeFunction()
+  }
+
+  return time.Now()
+}
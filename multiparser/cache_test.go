@@ -0,0 +1,199 @@
+package multiparser
+
+import (
+  . "github.com/scalingdata/check"
+  "testing"
+  "time"
+)
+
+type CacheTestSuite struct {
+  now time.Time
+}
+
+var _ = Suite(&CacheTestSuite{})
+
+func (s *CacheTestSuite) newCache(size int, ttl time.Duration) *FormatCache {
+  s.now = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+  cache := NewFormatCache(size, ttl)
+  cache.TimeFunction = func() time.Time { return s.now }
+  return cache
+}
+
+func (s *CacheTestSuite) TestGetPut(c *C) {
+  cache := s.newCache(0, 0)
+
+  _, ok := cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, false)
+
+  cache.Put("10.0.0.1", "rfc3164")
+  cache.Put("10.0.0.2", "rfc5424")
+  cache.Put("10.0.0.1", "rfc5424")
+
+  format, ok := cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, true)
+  c.Assert(format, Equals, "rfc5424")
+  c.Assert(cache.Len(), Equals, 2)
+}
+
+func (s *CacheTestSuite) TestTTL(c *C) {
+  cache := s.newCache(0, time.Minute)
+  cache.Put("10.0.0.1", "rfc3164")
+
+  s.now = s.now.Add(59 * time.Second)
+  _, ok := cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, true)
+
+  s.now = s.now.Add(time.Second)
+  _, ok = cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, false)
+  c.Assert(cache.Len(), Equals, 0)
+}
+
+func (s *CacheTestSuite) TestTTL_Parser(c *C) {
+  cache := s.newCache(0, time.Minute)
+  parser := NewRfcParser(&rfc3164ValidMsg).(*Parser)
+  parser.Cache = cache
+  parser.Source = "10.0.0.1"
+
+  // Each message parsed as the cached format renews it
+  for i := 0; i < 5; i++ {
+    parser.Reset(&rfc3164ValidMsg)
+    c.Assert(parser.Parse(), IsNil)
+    s.now = s.now.Add(30 * time.Second)
+  }
+
+  stats := cache.Stats()
+  c.Assert(stats.Hits, Equals, uint64(4))
+  c.Assert(stats.Misses, Equals, uint64(1))
+
+  s.now = s.now.Add(30 * time.Second)
+  _, ok := cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, false)
+}
+
+func (s *CacheTestSuite) TestSize(c *C) {
+  cache := s.newCache(2, 0)
+  cache.Put("10.0.0.1", "rfc3164")
+  cache.Put("10.0.0.2", "rfc3164")
+
+  // Makes 10.0.0.2 the least recently used
+  _, ok := cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, true)
+  cache.Put("10.0.0.3", "rfc5424")
+
+  c.Assert(cache.Len(), Equals, 2)
+  _, ok = cache.Get("10.0.0.2")
+  c.Assert(ok, Equals, false)
+  _, ok = cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, true)
+  c.Assert(cache.Stats().Evictions, Equals, uint64(1))
+}
+
+func (s *CacheTestSuite) TestSize_Parser(c *C) {
+  cache := s.newCache(2, 0)
+  parser := NewRfcParser(&rfc3164ValidMsg).(*Parser)
+  parser.Cache = cache
+
+  // The busiest source is kept
+  for _, source := range []string{"a", "b", "a", "a", "c"} {
+    parser.Reset(&rfc3164ValidMsg)
+    parser.Source = source
+    c.Assert(parser.Parse(), IsNil)
+  }
+
+  _, ok := cache.Get("a")
+  c.Assert(ok, Equals, true)
+  _, ok = cache.Get("b")
+  c.Assert(ok, Equals, false)
+}
+
+func (s *CacheTestSuite) TestZeroValue(c *C) {
+  cache := &FormatCache{Size: 1}
+  _, ok := cache.Get("10.0.0.1")
+  c.Assert(ok, Equals, false)
+  c.Assert(cache.Len(), Equals, 0)
+
+  cache.Put("10.0.0.1", "rfc3164")
+  cache.Put("10.0.0.2", "rfc5424")
+
+  format, ok := cache.Get("10.0.0.2")
+  c.Assert(ok, Equals, true)
+  c.Assert(format, Equals, "rfc5424")
+  c.Assert(cache.Len(), Equals, 1)
+}
+
+func (s *CacheTestSuite) TestParser(c *C) {
+  parsed := 0
+  r := NewRegistry(Rfc3164Format, Rfc5424Format)
+  c.Assert(r.Register(vendorFormat(-10, &parsed)), IsNil)
+
+  cache := s.newCache(0, 0)
+  buff := []byte("VENDOR 42")
+  parser := r.NewParser(&buff, nil)
+  parser.Cache = cache
+  parser.Source = "router"
+
+  // Detected, then taken from the cache
+  for i := 0; i < 3; i++ {
+    parser.Reset(&buff)
+    c.Assert(parser.Parse(), IsNil)
+    c.Assert(parser.Format(), Equals, "vendor")
+  }
+  c.Assert(parsed, Equals, 3)
+
+  // The cached format is tried first, then the others
+  buff = append([]byte(nil), rfc5424ValidMsg...)
+  parser.Reset(&buff)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Format(), Equals, "rfc5424")
+  c.Assert(parsed, Equals, 4)
+
+  format, _ := cache.Get("router")
+  c.Assert(format, Equals, "rfc5424")
+
+  stats := cache.Stats()
+  c.Assert(stats.Hits, Equals, uint64(2))
+  c.Assert(stats.Misses, Equals, uint64(2))
+  c.Assert(stats.HitRate(), Equals, 0.5)
+}
+
+func (s *CacheTestSuite) TestParser_NoSource(c *C) {
+  cache := s.newCache(0, 0)
+  parser := NewRfcParser(&rfc5424ValidMsg).(*Parser)
+  parser.Cache = cache
+
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(cache.Len(), Equals, 0)
+  c.Assert(cache.Stats(), Equals, CacheStats{})
+}
+
+func (s *CacheTestSuite) TestParser_ZeroAllocations(c *C) {
+  parser := NewRfcParser(&rfc3164ValidMsg).(*Parser)
+  parser.SetZeroCopy(true)
+  parser.Cache = NewFormatCache(16, time.Minute)
+  parser.Source = "10.0.0.1"
+  c.Assert(parser.Parse(), IsNil)
+
+  allocs := testing.AllocsPerRun(100, func() {
+    parser.Reset(&rfc3164ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  })
+  c.Assert(allocs, Equals, 0.0)
+}
+
+// Compare with BenchmarkReset5424, detecting the format, and BenchmarkTrialAndError5424
+func (s *CacheTestSuite) BenchmarkCached5424(c *C) {
+  parser := NewRfcParser(&rfc5424ValidMsg).(*Parser)
+  parser.SetZeroCopy(true)
+  parser.Cache = NewFormatCache(16, time.Minute)
+  parser.Source = "10.0.0.1"
+
+  for i := 0; i < c.N; i++ {
+    parser.Reset(&rfc5424ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  }
+}
@@ -7,10 +7,12 @@ import (
   message "github.com/scalingdata/syslogparser/message"
   "reflect"
   "github.com/scalingdata/syslogparser/rfc3164"
-  "github.com/scalingdata/syslogparser/rfc5424"
   "strings"
 )
 
+/* MultiParserError is returned when none of the parsers could parse the
+   message, ParseErrors holds the error of each of them in the order they
+   were tried. errors.Is and errors.As look through all of them. */
 type MultiParserError struct {
   ErrorString string
   ParseErrors []error
@@ -23,54 +25,221 @@ func (self MultiParserError) Error() string {
   return fmt.Sprintf("%v: %v", self.ErrorString, strings.Join(errorMsgs, ", "))
 }
 
+func (self MultiParserError) Unwrap() []error {
+  return self.ParseErrors
+}
+
 type Parser struct {
+  /* Cache, when not nil, makes the parser try the format the last message
+     of Source was parsed as before the others, see FormatCache. */
+  Cache *FormatCache
+  // Peer address or hostname the message comes from, set it before Parse
+  Source string
+
   rawMsg *[]byte
-  parsers []syslogparser.LogParser
-  successfulParser syslogparser.LogParser
+  candidates []candidate
+  // Indexes of the candidates in the order they are tried
+  order []int
+  successful *candidate
   failureMsg message.IMessage
 }
 
+// A parser of a format the message may be in
+type candidate struct {
+  format string
+  parser syslogparser.LogParser
+  // Format.Detect, nil when the format does not tell
+  detect func(buff []byte) syslogparser.Confidence
+  confidence syslogparser.Confidence
+}
+
+/* Parse tries the parsers in the order of the confidence their format has
+   in the message, those of the same confidence in the order they were
+   given. The format cached for the Source of the message, if any, is tried
+   first without detecting the others. */
 func (self *Parser) Parse() error {
   var parseErrors []error
 
-  for _,p := range self.parsers {
-    err := p.Parse()
+  cached := self.cachedCandidate()
+  if nil != cached {
+    err := cached.parser.Parse()
+    if nil == err {
+      self.Cache.hit(self.Source)
+      self.successful = cached
+      return nil
+    }
+
+    log.Debug("Unable to parse message from %s using its cached format %s due to '%s'", self.Source, cached.format, err)
+    parseErrors = append(parseErrors, err)
+  }
+
+  self.sortCandidates()
+
+  for _, i := range self.order {
+    c := &self.candidates[i]
+    if c == cached {
+      continue
+    }
+
+    err := c.parser.Parse()
     if nil != err {
-      log.Debug("Unable to parse message using %s due to '%s'", reflect.TypeOf(p), err)
+      log.Debug("Unable to parse message using %s due to '%s'", c.format, err)
       parseErrors = append(parseErrors, err)
     } else {
-      self.successfulParser = p
+      self.successful = c
+      if nil != self.Cache && self.Source != "" {
+        self.Cache.miss()
+        self.Cache.Put(self.Source, c.format)
+      }
       return nil
     }
   }
 
+  if nil != self.Cache && self.Source != "" {
+    self.Cache.miss()
+  }
+
   self.failureMsg = message.NewUnparsableMessage(self.rawMsg)
 
-  log.Debug("Unable to debug message, using empty LogParts for data")
+  log.Debug("Unable to parse message, using empty LogParts for data")
+  return &MultiParserError{"No parser could parse the message", parseErrors}
+}
+
+// cachedCandidate gives the first candidate of the format cached for Source
+func (self *Parser) cachedCandidate() *candidate {
+  if nil == self.Cache || self.Source == "" {
+    return nil
+  }
+
+  format, ok := self.Cache.Get(self.Source)
+  if !ok {
+    return nil
+  }
+
+  for i := range self.candidates {
+    if self.candidates[i].format == format {
+      return &self.candidates[i]
+    }
+  }
+
   return nil
 }
 
+// sortCandidates orders the candidates by decreasing confidence in the message
+func (self *Parser) sortCandidates() {
+  for i := range self.candidates {
+    c := &self.candidates[i]
+    c.confidence = syslogparser.POSSIBLE
+    if c.detect != nil && self.rawMsg != nil {
+      c.confidence = c.detect(*self.rawMsg)
+    }
+  }
+
+  // Insertion sort, stable and allocation free for a handful of formats
+  for i := 1; i < len(self.order); i++ {
+    for j := i; j > 0; j-- {
+      prev, cur := self.order[j-1], self.order[j]
+      if self.candidates[prev].confidence > self.candidates[cur].confidence ||
+        (self.candidates[prev].confidence == self.candidates[cur].confidence && prev < cur) {
+        break
+      }
+      self.order[j-1], self.order[j] = cur, prev
+    }
+  }
+}
+
+/* Reset prepares the parser and the parsers it tries for a new message, so
+   that it can be reused instead of calling NewRfcParser for every message. */
+func (self *Parser) Reset(rawMsg *[]byte) {
+  self.rawMsg = rawMsg
+  self.successful = nil
+  self.failureMsg = nil
+
+  for _, c := range self.candidates {
+    if r, ok := c.parser.(syslogparser.ResettableParser); ok {
+      r.Reset(rawMsg)
+    }
+  }
+}
+
+func (self *Parser) SetZeroCopy(zeroCopy bool) {
+  for _, c := range self.candidates {
+    if r, ok := c.parser.(syslogparser.ResettableParser); ok {
+      r.SetZeroCopy(zeroCopy)
+    }
+  }
+}
+
+// Dump gives the fields of the message along with the "format" it was parsed as
 func (self *Parser) Dump() syslogparser.LogParts {
-  if nil != self.successfulParser {
-    return self.successfulParser.Dump()
+  if nil != self.successful {
+    parts := self.successful.parser.Dump()
+    parts["format"] = self.successful.format
+    return parts
   } else {
     return nil
   }
 }
 
 func (self *Parser) Message() message.IMessage {
-  if nil != self.successfulParser {
-    return self.successfulParser.Message() 
+  if nil != self.successful {
+    return self.successful.parser.Message()
   } else {
     return self.failureMsg
   }
 }
 
+// Format gives the name of the format the message was parsed as, empty when it was not
+func (self *Parser) Format() string {
+  if nil != self.successful {
+    return self.successful.format
+  }
+
+  return ""
+}
+
+// addCandidate makes the parser try p, for the format of the given name
+func (self *Parser) addCandidate(format string, p syslogparser.LogParser, detect func(buff []byte) syslogparser.Confidence) {
+  self.order = append(self.order, len(self.candidates))
+  self.candidates = append(self.candidates, candidate{
+    format: format,
+    parser: p,
+    detect: detect,
+  })
+}
+
+/* NewParser creates a Parser trying the given parsers, all built for
+   rawMsg, in order. Their format is the name of their type, see Registry
+   for named formats. */
+func NewParser(rawMsg *[]byte, parsers ...syslogparser.LogParser) *Parser {
+  parser := &Parser{rawMsg: rawMsg}
+  for _, p := range parsers {
+    parser.addCandidate(reflect.TypeOf(p).String(), p, nil)
+  }
+
+  return parser
+}
+
 /* Create a Parser that uses all known RFC defined formats */
 func NewRfcParser(rawMsg *[]byte) syslogparser.LogParser {
-  parserSet := []syslogparser.LogParser{rfc3164.NewParser(rawMsg), rfc5424.NewParser(rawMsg)}
-  return &Parser{
-    rawMsg: rawMsg,
-    parsers: parserSet,
-  }
+  return newFormatsParser(rawMsg, nil, Rfc3164Format, Rfc5424Format)
+}
+
+/* Create a Parser that uses all known RFC defined formats, and falls back
+   to a lenient RFC 3164 parser keeping what it can of broken messages
+   rather than returning an UnparsableMessage. */
+func NewLenientRfcParser(rawMsg *[]byte) syslogparser.LogParser {
+  parser := newFormatsParser(rawMsg, nil, Rfc3164Format, Rfc5424Format)
+
+  lenient := rfc3164.NewParser(rawMsg)
+  lenient.Lenient = true
+  parser.addCandidate(Rfc3164Format.Name, lenient, lenientDetect)
+
+  return parser
+}
+
+/* The lenient fallback comes last : added after the other formats, it is
+   tried after them even when they do not match either. */
+func lenientDetect(buff []byte) syslogparser.Confidence {
+  return syslogparser.NO_MATCH
 }
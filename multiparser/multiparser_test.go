@@ -1,9 +1,13 @@
 package multiparser
 
 import (
+  "errors"
   . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
   "strings"
   syslogmsg "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
   "testing"
   "time"
 )
@@ -27,7 +31,10 @@ func (s *MultiParserTestSuite) TearDownTest(c *C) {
 }
 
 func (s *MultiParserTestSuite) TestRfc3164Message(c *C) {
-  parser := NewRfcParser(&rfc3164ValidMsg)
+  // The message has no year, keep it in 2014
+  rfc3164Parser := rfc3164.NewParser(&rfc3164ValidMsg)
+  rfc3164Parser.ReferenceTime = time.Date(2014, time.June, 1, 0, 0, 0, 0, time.UTC)
+  parser := NewParser(&rfc3164ValidMsg, rfc3164Parser, rfc5424.NewParser(&rfc3164ValidMsg))
   err := parser.Parse()
   if nil != err {
     c.Fatal(err)
@@ -69,17 +76,136 @@ func (s *MultiParserTestSuite) TestRfc5424Message(c *C) {
   c.Assert("This is a log.info() message in a fancy format", Equals, msg.Message())
   c.Assert("simlogging", Equals, msg.Process())
   c.Assert(string(rfc5424ValidMsg), Equals, string(*msg.RawMessage()))
+
+  rfcMsg, ok := msg.(rfc5424.IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert("ID47", Equals, rfcMsg.MsgId())
 }
 
 func (s *MultiParserTestSuite) TestInvalidMessage(c *C) {
   buff := []byte("FOO BAR BAXLKDFLKSJDLFKJ")
   parser := NewRfcParser(&buff)
   err := parser.Parse()
-  if nil != err {
-    c.Fatal(err)
+  if nil == err {
+    c.Fatal("No error returned")
   }
   msg := parser.Message()
   if nil == msg {
     c.Fatal("No message returned")
   }
+  _, unparsable := msg.(*syslogmsg.UnparsableMessage)
+  c.Assert(unparsable, Equals, true)
+
+  var multiErr *MultiParserError
+  c.Assert(errors.As(err, &multiErr), Equals, true)
+  c.Assert(multiErr.ParseErrors, HasLen, 2)
+  c.Assert(errors.Is(err, syslogparser.ErrPriorityNoStart), Equals, true)
+
+  var parseErr *syslogparser.PositionError
+  c.Assert(errors.As(multiErr.ParseErrors[1], &parseErr), Equals, true)
+  c.Assert(parseErr.Format, Equals, rfc5424.FORMAT)
+  c.Assert(parseErr.Field, Equals, syslogparser.FIELD_PRI)
+  c.Assert(parseErr.Offset, Equals, 0)
+}
+
+func (s *MultiParserTestSuite) TestLenientRfcParser(c *C) {
+  parser := NewLenientRfcParser(&rfc5424ValidMsg)
+  c.Assert(parser.Parse(), IsNil)
+  _, ok := parser.Message().(rfc5424.IMessage)
+  c.Assert(ok, Equals, true)
+
+  buff := []byte("<94>Jun 31 20:07:15 webtest-mark simlogging: broken timestamp")
+  parser = NewLenientRfcParser(&buff)
+  c.Assert(parser.Parse(), IsNil)
+
+  msg, ok := parser.Message().(rfc3164.IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert(msg.Severity(), Equals, syslogmsg.Info)
+  c.Assert(msg.Warnings(), HasLen, 1)
+  c.Assert(errors.Is(msg.Warnings()[0].Err, syslogparser.ErrTimestampUnknownFormat), Equals, true)
+
+  // Truncated after the HOSTNAME
+  buff = []byte("<34>Oct 11 22:14:15 myma")
+  buff = buff[:len(buff):len(buff)]
+  parser = NewLenientRfcParser(&buff)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Message().Hostname(), Equals, "myma")
+}
+
+func (s *MultiParserTestSuite) TestNewParser(c *C) {
+  parser := NewParser(&rfc3164ValidMsg, rfc5424.NewParser(&rfc3164ValidMsg))
+  err := parser.Parse()
+
+  var multiErr *MultiParserError
+  c.Assert(errors.As(err, &multiErr), Equals, true)
+  c.Assert(multiErr.ParseErrors, HasLen, 1)
+  _, unparsable := parser.Message().(*syslogmsg.UnparsableMessage)
+  c.Assert(unparsable, Equals, true)
+
+  parser = NewParser(&rfc5424ValidMsg, rfc5424.NewParser(&rfc5424ValidMsg))
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Dump()["msg_id"], Equals, "ID47")
+}
+
+func (s *MultiParserTestSuite) TestReset(c *C) {
+  parser := NewRfcParser(&rfc5424ValidMsg).(*Parser)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Message().Hostname(), Equals, "webtest-mark")
+
+  buff := []byte("FOO BAR BAZ")
+  parser.Reset(&buff)
+  c.Assert(parser.Parse(), NotNil)
+  _, unparsable := parser.Message().(*syslogmsg.UnparsableMessage)
+  c.Assert(unparsable, Equals, true)
+
+  parser.Reset(&rfc3164ValidMsg)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Message().Process(), Equals, "simlogging")
+}
+
+func (s *MultiParserTestSuite) TestReset_ZeroAllocations(c *C) {
+  parser := NewRfcParser(&rfc3164ValidMsg).(*Parser)
+  parser.SetZeroCopy(true)
+
+  allocs := testing.AllocsPerRun(100, func() {
+    parser.Reset(&rfc3164ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  })
+  c.Assert(allocs, Equals, 0.0)
+}
+
+func (s *MultiParserTestSuite) BenchmarkReset3164(c *C) {
+  parser := NewRfcParser(&rfc3164ValidMsg).(*Parser)
+  parser.SetZeroCopy(true)
+
+  for i := 0; i < c.N; i++ {
+    parser.Reset(&rfc3164ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  }
+}
+
+// RFC 5424 messages are detected as such, compare with BenchmarkTrialAndError5424
+func (s *MultiParserTestSuite) BenchmarkReset5424(c *C) {
+  parser := NewRfcParser(&rfc5424ValidMsg).(*Parser)
+  parser.SetZeroCopy(true)
+
+  for i := 0; i < c.N; i++ {
+    parser.Reset(&rfc5424ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  }
+}
+
+func (s *MultiParserTestSuite) BenchmarkNewRfcParser(c *C) {
+  for i := 0; i < c.N; i++ {
+    parser := NewRfcParser(&rfc3164ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  }
 }
@@ -0,0 +1,230 @@
+package multiparser
+
+import (
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/cisco"
+  "github.com/scalingdata/syslogparser/junos"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "sort"
+  "sync"
+  "time"
+)
+
+// Options read by the built-in formats, others are free to define their own
+const (
+  // *time.Location of RFC 3164, Junos BSD and Cisco timestamps, see rfc3164.Parser.Location
+  OPTION_LOCATION = "location"
+  // map[string]*time.Location, see rfc3164.Parser.Zones and cisco.Parser.Zones
+  OPTION_ZONES = "zones"
+  // time.Time, see rfc3164.Parser.ReferenceTime and cisco.Parser.ReferenceTime
+  OPTION_REFERENCE_TIME = "reference_time"
+  // syslogparser.CharsetDecoder of RFC 3164 messages, see rfc3164.Parser.Decoder
+  OPTION_DECODER = "decoder"
+  // bool, see rfc3164.Parser.DetectCharset
+  OPTION_DETECT_CHARSET = "detect_charset"
+)
+
+var ErrFormatRegistered = &syslogparser.ParserError{"Format already registered"}
+
+// Settings of the parsers built by a Format, by option name
+type Options map[string]interface{}
+
+/* Format describes a message format to a Registry : New builds its parser
+   and Detect, when not nil, sniffs how likely a message is in the format so
+   that the most likely formats are tried first. */
+type Format struct {
+  Name string
+  // Formats of higher priority are tried first among equally likely ones
+  Priority int
+  New      func(buff *[]byte, opts Options) syslogparser.LogParser
+  Detect   func(buff []byte) syslogparser.Confidence
+}
+
+var (
+  Rfc3164Format = Format{
+    Name:     "rfc3164",
+    Priority: 10,
+    New:      newRfc3164Parser,
+    Detect:   rfc3164.Detect,
+  }
+
+  Rfc5424Format = Format{
+    Name:     "rfc5424",
+    Priority: 0,
+    New:      newRfc5424Parser,
+    Detect:   rfc5424.Detect,
+  }
+
+  // Tried after RFC 5424 when both are certain, IOS XE can send RFC 5424 messages
+  CiscoFormat = Format{
+    Name:     "cisco",
+    Priority: -10,
+    New:      newCiscoParser,
+    Detect:   cisco.Detect,
+  }
+
+  // Tried before RFC 5424 and RFC 3164, which Junos messages are as well
+  JunosFormat = Format{
+    Name:     "junos",
+    Priority: 20,
+    New:      newJunosParser,
+    Detect:   junos.Detect,
+  }
+
+  // Registry of the built-in formats, vendor formats can be added to it
+  DefaultRegistry = NewRegistry(Rfc3164Format, Rfc5424Format, CiscoFormat, JunosFormat)
+)
+
+// Registry holds the formats a Parser built by NewParser tries
+type Registry struct {
+  mutex   sync.RWMutex
+  // By decreasing priority
+  formats []Format
+}
+
+func NewRegistry(formats ...Format) *Registry {
+  r := &Registry{}
+  for _, f := range formats {
+    r.Register(f)
+  }
+
+  return r
+}
+
+// Register adds a format, it fails if one of the same name is already registered
+func (r *Registry) Register(f Format) error {
+  r.mutex.Lock()
+  defer r.mutex.Unlock()
+
+  for _, registered := range r.formats {
+    if registered.Name == f.Name {
+      return ErrFormatRegistered
+    }
+  }
+
+  r.formats = append(r.formats, f)
+  sort.SliceStable(r.formats, func(i, j int) bool {
+    return r.formats[i].Priority > r.formats[j].Priority
+  })
+
+  return nil
+}
+
+// Lookup gives the format registered under name
+func (r *Registry) Lookup(name string) (Format, bool) {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  for _, f := range r.formats {
+    if f.Name == name {
+      return f, true
+    }
+  }
+
+  return Format{}, false
+}
+
+// Formats lists the registered formats by decreasing priority
+func (r *Registry) Formats() []Format {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  return append([]Format(nil), r.formats...)
+}
+
+/* Detect gives the most likely format of buff, and how likely it is,
+   without parsing it : a message can be routed to its parser with it. The
+   format of highest priority wins among equally likely ones. */
+func (r *Registry) Detect(buff []byte) (Format, syslogparser.Confidence) {
+  r.mutex.RLock()
+  defer r.mutex.RUnlock()
+
+  var best Format
+  confidence := syslogparser.NO_MATCH
+  for _, f := range r.formats {
+    c := syslogparser.POSSIBLE
+    if f.Detect != nil {
+      c = f.Detect(buff)
+    }
+
+    if c > confidence {
+      best, confidence = f, c
+    }
+  }
+
+  return best, confidence
+}
+
+/* NewParser creates a Parser trying all the registered formats, their
+   parsers being built with opts. */
+func (r *Registry) NewParser(rawMsg *[]byte, opts Options) *Parser {
+  return newFormatsParser(rawMsg, opts, r.Formats()...)
+}
+
+func newFormatsParser(rawMsg *[]byte, opts Options, formats ...Format) *Parser {
+  parser := &Parser{rawMsg: rawMsg}
+  for _, f := range formats {
+    parser.addCandidate(f.Name, f.New(rawMsg, opts), f.Detect)
+  }
+
+  return parser
+}
+
+func newRfc3164Parser(buff *[]byte, opts Options) syslogparser.LogParser {
+  p := rfc3164.NewParser(buff)
+  setRfc3164Options(p, opts)
+
+  return p
+}
+
+func setRfc3164Options(p *rfc3164.Parser, opts Options) {
+  if loc, ok := opts[OPTION_LOCATION].(*time.Location); ok {
+    p.Location = loc
+  }
+
+  if zones, ok := opts[OPTION_ZONES].(map[string]*time.Location); ok {
+    p.Zones = zones
+  }
+
+  if reference, ok := opts[OPTION_REFERENCE_TIME].(time.Time); ok {
+    p.ReferenceTime = reference
+  }
+
+  if decoder, ok := opts[OPTION_DECODER].(syslogparser.CharsetDecoder); ok {
+    p.Decoder = decoder
+  }
+
+  if detect, ok := opts[OPTION_DETECT_CHARSET].(bool); ok {
+    p.DetectCharset = detect
+  }
+}
+
+func newJunosParser(buff *[]byte, opts Options) syslogparser.LogParser {
+  p := junos.NewParser(buff)
+  setRfc3164Options(p.BSD, opts)
+
+  return p
+}
+
+func newCiscoParser(buff *[]byte, opts Options) syslogparser.LogParser {
+  p := cisco.NewParser(buff)
+
+  if loc, ok := opts[OPTION_LOCATION].(*time.Location); ok {
+    p.Location = loc
+  }
+
+  if zones, ok := opts[OPTION_ZONES].(map[string]*time.Location); ok {
+    p.Zones = zones
+  }
+
+  if reference, ok := opts[OPTION_REFERENCE_TIME].(time.Time); ok {
+    p.ReferenceTime = reference
+  }
+
+  return p
+}
+
+func newRfc5424Parser(buff *[]byte, opts Options) syslogparser.LogParser {
+  return rfc5424.NewParser(buff)
+}
@@ -0,0 +1,229 @@
+package multiparser
+
+import (
+  "bytes"
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  syslogmsg "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/rfc3164"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "time"
+)
+
+type RegistryTestSuite struct {
+}
+
+var _ = Suite(&RegistryTestSuite{})
+
+// Parses messages starting with "VENDOR", counting its attempts
+type vendorParser struct {
+  buff   *[]byte
+  parsed *int
+}
+
+func (p *vendorParser) Parse() error {
+  *p.parsed++
+  if !bytes.HasPrefix(*p.buff, []byte("VENDOR")) {
+    return syslogparser.ErrPriorityNoStart
+  }
+  return nil
+}
+
+func (p *vendorParser) Dump() syslogparser.LogParts {
+  return syslogparser.LogParts{"content": string(*p.buff)}
+}
+
+func (p *vendorParser) Message() syslogmsg.IMessage {
+  return nil
+}
+
+func vendorFormat(priority int, parsed *int) Format {
+  return Format{
+    Name:     "vendor",
+    Priority: priority,
+    New: func(buff *[]byte, opts Options) syslogparser.LogParser {
+      return &vendorParser{buff, parsed}
+    },
+    Detect: func(buff []byte) syslogparser.Confidence {
+      if bytes.HasPrefix(buff, []byte("VENDOR")) {
+        return syslogparser.CERTAIN
+      }
+      return syslogparser.NO_MATCH
+    },
+  }
+}
+
+func (s *RegistryTestSuite) TestRegister(c *C) {
+  parsed := 0
+  r := NewRegistry(Rfc5424Format, Rfc3164Format)
+  c.Assert(r.Register(vendorFormat(5, &parsed)), IsNil)
+  c.Assert(r.Register(vendorFormat(5, &parsed)), Equals, ErrFormatRegistered)
+
+  var names []string
+  for _, f := range r.Formats() {
+    names = append(names, f.Name)
+  }
+  c.Assert(names, DeepEquals, []string{"rfc3164", "vendor", "rfc5424"})
+
+  f, ok := r.Lookup("rfc5424")
+  c.Assert(ok, Equals, true)
+  c.Assert(f.Name, Equals, "rfc5424")
+
+  _, ok = r.Lookup("cisco")
+  c.Assert(ok, Equals, false)
+}
+
+func (s *RegistryTestSuite) TestNewParser_DetectOrder(c *C) {
+  parsed := 0
+  r := NewRegistry(Rfc3164Format, Rfc5424Format)
+  c.Assert(r.Register(vendorFormat(-10, &parsed)), IsNil)
+
+  // Tried first despite its priority
+  buff := []byte("VENDOR 42")
+  parser := r.NewParser(&buff, nil)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Format(), Equals, "vendor")
+  c.Assert(parser.Dump()["format"], Equals, "vendor")
+
+  // Tried after the formats that match
+  parsed = 0
+  parser.Reset(&rfc5424ValidMsg)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Format(), Equals, "rfc5424")
+  c.Assert(parser.Dump()["format"], Equals, "rfc5424")
+  c.Assert(parsed, Equals, 0)
+
+  // Tried last
+  buff = []byte("FOO BAR BAZ")
+  parser.Reset(&buff)
+  c.Assert(parser.Parse(), NotNil)
+  c.Assert(parser.Format(), Equals, "")
+  c.Assert(parsed, Equals, 1)
+}
+
+func (s *RegistryTestSuite) TestNewParser_Options(c *C) {
+  opts := Options{
+    OPTION_LOCATION:       time.FixedZone("JST", 9*3600),
+    OPTION_REFERENCE_TIME: time.Date(2014, time.June, 1, 0, 0, 0, 0, time.UTC),
+  }
+
+  parser := DefaultRegistry.NewParser(&rfc3164ValidMsg, opts)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Format(), Equals, "rfc3164")
+  c.Assert(parser.Message().TimeStamp(), Equals, time.Date(2014, time.June, 6, 11, 7, 15, 0, time.UTC))
+
+  buff := []byte("<189>Jun  6 12:00:00 CET: router su: msg")
+  opts[OPTION_ZONES] = map[string]*time.Location{"CET": time.FixedZone("CET", 3600)}
+  parser = DefaultRegistry.NewParser(&buff, opts)
+  c.Assert(parser.Parse(), IsNil)
+  c.Assert(parser.Message().TimeStamp(), Equals, time.Date(2014, time.June, 6, 11, 0, 0, 0, time.UTC))
+}
+
+func (s *RegistryTestSuite) TestNewParser_Truncated(c *C) {
+  fixtures := []string{
+    "<34>1 2003-10-11T22:14:15",
+    "<34>Oct 11 22:14:15 mymachine su[",
+    "<189>Mar  1 12:00:00 srx1 mgd[3046]",
+  }
+
+  for _, f := range fixtures {
+    // Without spare capacity, reading past the end panics
+    buff := []byte(f)
+    buff = buff[:len(buff):len(buff)]
+    DefaultRegistry.NewParser(&buff, nil).Parse()
+  }
+}
+
+func (s *RegistryTestSuite) TestDetect(c *C) {
+  fixtures := []struct {
+    buff    string
+    rfc3164 syslogparser.Confidence
+    rfc5424 syslogparser.Confidence
+  }{
+    {string(rfc3164ValidMsg), syslogparser.LIKELY, syslogparser.UNLIKELY},
+    {string(rfc5424ValidMsg), syslogparser.UNLIKELY, syslogparser.CERTAIN},
+    {"<34>1 - host su - - - msg", syslogparser.UNLIKELY, syslogparser.CERTAIN},
+    {"<34>1 Oct 11 22:14:15 host su - - - msg", syslogparser.UNLIKELY, syslogparser.UNLIKELY},
+    {"<34>2024-03-01T12:00:00Z host su: msg", syslogparser.LIKELY, syslogparser.UNLIKELY},
+    {"<34>Octember 11 22:14:15 host su: msg", syslogparser.UNLIKELY, syslogparser.UNLIKELY},
+    {"FOO BAR BAZ", syslogparser.NO_MATCH, syslogparser.NO_MATCH},
+  }
+
+  for _, f := range fixtures {
+    c.Assert(rfc3164.Detect([]byte(f.buff)), Equals, f.rfc3164, Commentf(f.buff))
+    c.Assert(rfc5424.Detect([]byte(f.buff)), Equals, f.rfc5424, Commentf(f.buff))
+  }
+}
+
+// Messages as sent by common senders, by format
+var detectCorpus = []struct {
+  format string
+  buff   string
+}{
+  {"rfc3164", "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8"},
+  {"rfc3164", "<13>Feb  5 17:32:18 10.0.0.99 Use the BFG!"},
+  {"rfc3164", "<165>Aug 24 05:34:00 CST 1987 mymachine myproc[10]: %% It's time to make the do-nuts."},
+  {"rfc3164", "<0>Oct 22 10:52:12 scapegoat 1990 Oct 22 10:52:01 TZ-6 scapegoat.dmz.example.org 10.1.2.3 sched[0]: That's All Folks!"},
+  {"cisco", "<189>Mar  1 12:00:00.123 UTC: router %SYS-5-CONFIG_I: Configured from console"},
+  {"cisco", "<189>*Mar  1 00:00:41 UTC: router %LINK-3-UPDOWN: Interface changed state to up"},
+  {"cisco", "<189>123: router1: *Mar  1 18:46:11.123 UTC: %LINK-3-UPDOWN: Interface Fa0/1, changed state to up"},
+  {"cisco", "<189>: 2024 Mar  1 18:46:11 UTC: %ETHPORT-5-IF_UP: Interface Ethernet1/1 is up in mode access"},
+  {"cisco", "<166>%ASA-6-302013: Built inbound TCP connection 1 for outside:10.0.0.1/443"},
+  {"rfc5424", "<189>1 2024-03-01T18:46:11.123Z router1 - - - - %LINK-3-UPDOWN: Interface Fa0/1, changed state to up"},
+  {"rfc3164", "<30>2024-03-01T12:00:00.123456+01:00 esxi hostd[2099]: Task created"},
+  {"junos", "<28>Mar  1 2024 12:00:00 srx mgd[1234]: UI_COMMIT: User committed"},
+  {"junos", "<189>Mar  1 12:00:00 srx1 mgd[3046]: %INTERACT-6-UI_CMDLINE_READ_LINE: User 'admin', command 'show version'"},
+  {"junos", "<189>Mar  1 12:00:00 srx1 mgd: %INTERACT-6-UI_CMDLINE_READ_LINE: User 'admin', command 'show version'"},
+  {"rfc3164", "<34>Oct 11 22:14:15 nms poller: router1 sent %LINK-3-UPDOWN: Interface Fa0/1 changed state to down"},
+  {"rfc3164", "<191>Jan 17 09:01:04 2015 sw1 sshd[42]: Accepted publickey for admin"},
+  {"rfc3164", string(rfc3164ValidMsg)},
+  {"rfc5424", "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - BOM'su root' failed for lonvick on /dev/pts/8"},
+  {"rfc5424", "<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts."},
+  {"rfc5424", "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut=\"3\" eventSource=\"Application\" eventID=\"1011\"] An application event log entry..."},
+  {"rfc5424", "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut=\"3\"][examplePriority@32473 class=\"high\"]"},
+  {"rfc5424", "<14>1 - - - - - -"},
+  {"junos", "<28>1 2024-03-01T12:00:00.123Z srx RT_FLOW - RT_FLOW_SESSION_CREATE [junos@2636.1.1.1.2.26 source-address=\"10.0.0.1\"] session created"},
+  {"rfc5424", string(rfc5424ValidMsg)},
+}
+
+// The format detected for each message of the corpus is the one it parses as
+func (s *RegistryTestSuite) TestDetect_Corpus(c *C) {
+  for _, f := range detectCorpus {
+    buff := []byte(f.buff)
+
+    format, confidence := DefaultRegistry.Detect(buff)
+    c.Assert(format.Name, Equals, f.format, Commentf(f.buff))
+    c.Assert(confidence >= syslogparser.LIKELY, Equals, true, Commentf(f.buff))
+
+    c.Assert(format.New(&buff, nil).Parse(), IsNil, Commentf(f.buff))
+
+    parser := DefaultRegistry.NewParser(&buff, nil)
+    c.Assert(parser.Parse(), IsNil, Commentf(f.buff))
+    c.Assert(parser.Format(), Equals, f.format, Commentf(f.buff))
+  }
+}
+
+func (s *RegistryTestSuite) TestDetect_NoMatch(c *C) {
+  format, confidence := DefaultRegistry.Detect([]byte("FOO BAR BAZ"))
+  c.Assert(format.Name, Equals, "")
+  c.Assert(confidence, Equals, syslogparser.NO_MATCH)
+}
+
+func (s *RegistryTestSuite) BenchmarkDetect(c *C) {
+  for i := 0; i < c.N; i++ {
+    DefaultRegistry.Detect(rfc5424ValidMsg)
+  }
+}
+
+// The cost of trying RFC 5424 messages as RFC 3164 first, without detection
+func (s *RegistryTestSuite) BenchmarkTrialAndError5424(c *C) {
+  parser := NewParser(&rfc5424ValidMsg, rfc3164.NewParser(&rfc5424ValidMsg), rfc5424.NewParser(&rfc5424ValidMsg))
+  parser.SetZeroCopy(true)
+
+  for i := 0; i < c.N; i++ {
+    parser.Reset(&rfc5424ValidMsg)
+    if err := parser.Parse(); err != nil {
+      panic(err)
+    }
+  }
+}
@@ -0,0 +1,118 @@
+// Parses a stream of syslog messages, eg. an archived log file
+
+package reader
+
+import (
+  "fmt"
+  "io"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/framing"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/multiparser"
+)
+
+var (
+  ErrUnparsable = &syslogparser.ParserError{"Unparsable message"}
+)
+
+type Options struct {
+  // Messages are one per line when left to framing.AutoDetect's zero value
+  Framing       framing.Method
+  MaxLineLength int
+  ParserFactory syslogparser.ParserFactory
+}
+
+/* LineError reports a frame that could not be turned into a message, the
+   Reader is still usable after it. */
+type LineError struct {
+  // 1-based line of the stream the frame starts on, see framing.Decoder.Line
+  Line int64
+  Err  error
+}
+
+func (err *LineError) Error() string {
+  return fmt.Sprintf("line %d: %s", err.Line, err.Err)
+}
+
+func (err *LineError) Unwrap() error {
+  return err.Err
+}
+
+type Reader struct {
+  d             *framing.Decoder
+  parserFactory syslogparser.ParserFactory
+}
+
+func NewReader(r io.Reader, opts Options) *Reader {
+  method := opts.Framing
+  if method == framing.AutoDetect {
+    // Log files usually lack the PRI auto detection relies on
+    method = framing.NonTransparent
+  }
+
+  d := framing.NewDecoder(r, method)
+  if opts.MaxLineLength > 0 {
+    d.MaxFrameSize = opts.MaxLineLength
+  }
+
+  parserFactory := opts.ParserFactory
+  if parserFactory == nil {
+    parserFactory = multiparser.NewRfcParser
+  }
+
+  return &Reader{
+    d:             d,
+    parserFactory: parserFactory,
+  }
+}
+
+/* Next returns the next message, or io.EOF at the end of the stream.
+   A *LineError is returned for lines too long or unparsable, along with a
+   message.UnparsableMessage in the latter case, and reading can go on.
+   Any other error is fatal. */
+func (r *Reader) Next() (message.IMessage, error) {
+  frame, err := r.d.Next()
+  if err == io.EOF {
+    return nil, err
+  }
+
+  if err == framing.ErrFrameTooLarge {
+    return nil, &LineError{r.d.Line(), err}
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  msg, err := r.parse(frame)
+  if err == nil {
+    if _, unparsable := msg.(*message.UnparsableMessage); unparsable {
+      err = ErrUnparsable
+    }
+  }
+
+  if err != nil {
+    return msg, &LineError{r.d.Line(), err}
+  }
+
+  return msg, nil
+}
+
+/* parse runs frame through the parser of the ParserFactory, a parser
+   panicking on it giving an UnparsableMessage. */
+func (r *Reader) parse(frame []byte) (msg message.IMessage, err error) {
+  defer func() {
+    if p := recover(); p != nil {
+      msg = message.NewUnparsableMessage(&frame)
+      err = fmt.Errorf("%w: parser panicked: %v", ErrUnparsable, p)
+    }
+  }()
+
+  p := r.parserFactory(&frame)
+  err = p.Parse()
+  return p.Message(), err
+}
+
+// Line returns the 1-based line of the stream the last message read starts on
+func (r *Reader) Line() int64 {
+  return r.d.Line()
+}
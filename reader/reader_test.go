@@ -0,0 +1,149 @@
+package reader
+
+import (
+  "errors"
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/framing"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/multiparser"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "io"
+  "strconv"
+  "strings"
+  "testing"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type ReaderTestSuite struct {
+}
+
+var _ = Suite(&ReaderTestSuite{})
+
+const (
+  msg3164 = "<34>Oct 11 22:14:15 mymachine su: 'su root' failed"
+  msg5424 = "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event"
+)
+
+func (s *ReaderTestSuite) TestNext_Lines(c *C) {
+  stream := msg3164 + "\n" + msg5424 + "\r\n\n" + msg3164
+
+  r := NewReader(strings.NewReader(stream), Options{})
+  for _, expected := range []string{"mymachine", "mymachine.example.com", "mymachine"} {
+    msg, err := r.Next()
+    c.Assert(err, IsNil)
+    c.Assert(msg.Hostname(), Equals, expected)
+  }
+
+  _, err := r.Next()
+  c.Assert(err, Equals, io.EOF)
+  // Empty lines are counted
+  c.Assert(r.Line(), Equals, int64(4))
+}
+
+func (s *ReaderTestSuite) TestNext_ContinuesPastErrors(c *C) {
+  stream := "FOO BAR BAZ\n" + strings.Repeat("x", 200) + "\n" + msg5424 + "\n"
+
+  r := NewReader(strings.NewReader(stream), Options{MaxLineLength: 100})
+
+  msg, err := r.Next()
+  c.Assert(err, FitsTypeOf, &LineError{})
+  c.Assert(err.(*LineError).Line, Equals, int64(1))
+  var multiErr *multiparser.MultiParserError
+  c.Assert(errors.As(err, &multiErr), Equals, true)
+  c.Assert(errors.Is(err, syslogparser.ErrPriorityNoStart), Equals, true)
+  c.Assert(string(*msg.RawMessage()), Equals, "FOO BAR BAZ")
+  _, ok := msg.(*message.UnparsableMessage)
+  c.Assert(ok, Equals, true)
+
+  msg, err = r.Next()
+  c.Assert(err, DeepEquals, &LineError{2, framing.ErrFrameTooLarge})
+  c.Assert(msg, IsNil)
+
+  msg, err = r.Next()
+  c.Assert(err, IsNil)
+  c.Assert(msg.Message(), Equals, "An application event")
+
+  _, err = r.Next()
+  c.Assert(err, Equals, io.EOF)
+}
+
+func (s *ReaderTestSuite) TestNext_OctetCountingWithParser(c *C) {
+  stream := strconv.Itoa(len(msg5424)) + " " + msg5424 + strconv.Itoa(len(msg3164)) + " " + msg3164
+
+  opts := Options{
+    Framing: framing.OctetCounting,
+    ParserFactory: func(buff *[]byte) syslogparser.LogParser { return rfc5424.NewParser(buff) },
+  }
+  r := NewReader(strings.NewReader(stream), opts)
+
+  msg, err := r.Next()
+  c.Assert(err, IsNil)
+  c.Assert(msg.Message(), Equals, "An application event")
+
+  // Parser errors are reported as is
+  _, err = r.Next()
+  lineErr, ok := err.(*LineError)
+  c.Assert(ok, Equals, true)
+  // Both frames are on the first line
+  c.Assert(lineErr.Line, Equals, int64(1))
+  c.Assert(lineErr.Err, NotNil)
+
+  _, err = r.Next()
+  c.Assert(err, Equals, io.EOF)
+}
+
+func (s *ReaderTestSuite) TestNext_ErrorLinesAfterEmptyLines(c *C) {
+  stream := "\n\n" + msg3164 + "\n\nFOO BAR BAZ\n"
+
+  r := NewReader(strings.NewReader(stream), Options{})
+  _, err := r.Next()
+  c.Assert(err, IsNil)
+  c.Assert(r.Line(), Equals, int64(3))
+
+  _, err = r.Next()
+  c.Assert(err, FitsTypeOf, &LineError{})
+  c.Assert(err.(*LineError).Line, Equals, int64(5))
+}
+
+type panickingParser struct {
+  syslogparser.LogParser
+}
+
+func (p panickingParser) Parse() error {
+  panic("malformed")
+}
+
+func (s *ReaderTestSuite) TestNext_ParserPanic(c *C) {
+  opts := Options{
+    ParserFactory: func(buff *[]byte) syslogparser.LogParser { return panickingParser{} },
+  }
+  r := NewReader(strings.NewReader(msg3164 + "\n" + msg5424), opts)
+
+  for line := int64(1); line <= 2; line++ {
+    msg, err := r.Next()
+    c.Assert(err, FitsTypeOf, &LineError{})
+    c.Assert(err.(*LineError).Line, Equals, line)
+    c.Assert(errors.Is(err, ErrUnparsable), Equals, true)
+    _, ok := msg.(*message.UnparsableMessage)
+    c.Assert(ok, Equals, true)
+  }
+
+  _, err := r.Next()
+  c.Assert(err, Equals, io.EOF)
+}
+
+func (s *ReaderTestSuite) TestNext_TruncatedPid(c *C) {
+  r := NewReader(strings.NewReader("<34>Oct 11 22:14:15 host su["), Options{})
+  msg, err := r.Next()
+  c.Assert(err, IsNil)
+  c.Assert(msg.Hostname(), Equals, "host")
+}
+
+func (s *ReaderTestSuite) TestNext_FatalError(c *C) {
+  r := NewReader(strings.NewReader("1x"), Options{Framing: framing.OctetCounting})
+  _, err := r.Next()
+  c.Assert(err, Equals, framing.ErrInvalidMsgLen)
+}
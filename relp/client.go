@@ -0,0 +1,249 @@
+package relp
+
+import (
+  "bufio"
+  "fmt"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "net"
+  "sort"
+  "sync"
+)
+
+// Set once the server acknowledged our close
+var errClosed = &ProtocolError{"RELP session closed"}
+
+type MessageFormatter interface {
+  Format(msg message.IMessage) []byte
+}
+
+/* Client sends syslog commands with up to Window of them waiting for their
+   acknowledgement. Once the session failed, Unacknowledged returns what
+   has to be resent on a new session. */
+type Client struct {
+  Formatter MessageFormatter
+
+  conn      net.Conn
+  window    int
+  mu        sync.Mutex
+  cond      *sync.Cond
+  txnr      int
+  pending   map[int][]byte
+  err       error
+  done      chan struct{}
+}
+
+func Dial(network string, addr string, window int) (*Client, error) {
+  conn, err := net.Dial(network, addr)
+  if err != nil {
+    return nil, err
+  }
+
+  c, err := NewClient(conn, window)
+  if err != nil {
+    conn.Close()
+    return nil, err
+  }
+
+  return c, nil
+}
+
+// NewClient opens a RELP session over conn, eg. a tls.Conn
+func NewClient(conn net.Conn, window int) (*Client, error) {
+  if window <= 0 {
+    window = DEFAULT_WINDOW
+  }
+
+  c := &Client{
+    Formatter: rfc5424.NewFormatter(),
+    conn:      conn,
+    window:    window,
+    txnr:      1,
+    pending:   make(map[int][]byte),
+    done:      make(chan struct{}),
+  }
+  c.cond = sync.NewCond(&c.mu)
+
+  r := bufio.NewReader(conn)
+
+  offers := fmt.Sprintf("relp_version=%s\nrelp_software=syslogparser\ncommands=%s", RELP_VERSION, CMD_SYSLOG)
+  if err := WriteFrame(conn, Frame{c.nextTxnr(), CMD_OPEN, []byte(offers)}); err != nil {
+    return nil, err
+  }
+
+  f, err := ReadFrame(r, DEFAULT_MAX_DATA_SIZE)
+  if err != nil {
+    return nil, err
+  }
+
+  if f.Command != CMD_RSP {
+    return nil, ErrInvalidCommand
+  }
+
+  if code, msg := parseResponse(f.Data); code != 200 {
+    return nil, &ProtocolError{fmt.Sprintf("RELP open refused: %d %s", code, msg)}
+  }
+
+  go c.readResponses(r)
+
+  return c, nil
+}
+
+// Send blocks while the window is full
+func (c *Client) Send(payload []byte) error {
+  if payload == nil {
+    payload = []byte{}
+  }
+
+  c.mu.Lock()
+  defer c.mu.Unlock()
+
+  for c.err == nil && len(c.pending) >= c.window {
+    c.cond.Wait()
+  }
+
+  if c.err != nil {
+    return c.err
+  }
+
+  txnr := c.nextTxnr()
+  c.pending[txnr] = payload
+
+  if err := WriteFrame(c.conn, Frame{txnr, CMD_SYSLOG, payload}); err != nil {
+    c.fail(err)
+    return err
+  }
+
+  return nil
+}
+
+func (c *Client) SendMessage(msg message.IMessage) error {
+  return c.Send(c.Formatter.Format(msg))
+}
+
+// Flush waits for every message sent to be acknowledged
+func (c *Client) Flush() error {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+
+  for c.err == nil && len(c.pending) > 0 {
+    c.cond.Wait()
+  }
+
+  return c.err
+}
+
+// Unacknowledged returns the payloads not acknowledged yet, in sending order
+func (c *Client) Unacknowledged() [][]byte {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+
+  txnrs := make([]int, 0, len(c.pending))
+  for txnr := range c.pending {
+    txnrs = append(txnrs, txnr)
+  }
+  // Transaction numbers wrap, the oldest follow the gap
+  sort.Ints(txnrs)
+  for i := 1; i < len(txnrs); i++ {
+    if txnrs[i]-txnrs[i-1] > c.window {
+      txnrs = append(txnrs[i:], txnrs[:i]...)
+      break
+    }
+  }
+
+  payloads := make([][]byte, 0, len(txnrs))
+  for _, txnr := range txnrs {
+    if payload := c.pending[txnr]; payload != nil {
+      payloads = append(payloads, payload)
+    }
+  }
+
+  return payloads
+}
+
+// Close waits for pending acknowledgements then ends the session
+func (c *Client) Close() error {
+  err := c.Flush()
+
+  if err == nil {
+    c.mu.Lock()
+    txnr := c.nextTxnr()
+    // close has no payload to resend, it only waits for its rsp
+    c.pending[txnr] = nil
+    err = WriteFrame(c.conn, Frame{txnr, CMD_CLOSE, nil})
+    c.mu.Unlock()
+
+    if err == nil {
+      err = c.Flush()
+    }
+  }
+
+  c.conn.Close()
+  <-c.done
+
+  if err == errClosed {
+    return nil
+  }
+  return err
+}
+
+func (c *Client) readResponses(r *bufio.Reader) {
+  defer close(c.done)
+
+  for {
+    f, err := ReadFrame(r, DEFAULT_MAX_DATA_SIZE)
+
+    c.mu.Lock()
+    if err != nil {
+      c.fail(err)
+      c.mu.Unlock()
+      return
+    }
+
+    switch f.Command {
+    case CMD_RSP:
+      payload, ok := c.pending[f.Txnr]
+      if !ok {
+        c.fail(ErrInvalidTxnr)
+      } else if payload == nil {
+        // rsp to our close
+        delete(c.pending, f.Txnr)
+        c.fail(errClosed)
+      } else if code, msg := parseResponse(f.Data); code != 200 {
+        c.fail(&ProtocolError{fmt.Sprintf("RELP message rejected: %d %s", code, msg)})
+      } else {
+        delete(c.pending, f.Txnr)
+      }
+    case CMD_SERVERCLOSE:
+      c.fail(ErrServerClosed)
+    default:
+      c.fail(ErrInvalidCommand)
+    }
+
+    failed := c.err != nil
+    c.cond.Broadcast()
+    c.mu.Unlock()
+
+    if failed {
+      return
+    }
+  }
+}
+
+// Must be called with mu held, only the first failure is kept
+func (c *Client) fail(err error) {
+  if c.err == nil {
+    c.err = err
+  }
+  c.cond.Broadcast()
+}
+
+// Must be called with mu held
+func (c *Client) nextTxnr() int {
+  txnr := c.txnr
+  c.txnr++
+  if c.txnr > maxTxnr {
+    c.txnr = 1
+  }
+  return txnr
+}
@@ -0,0 +1,221 @@
+// Reliable Event Logging Protocol, see http://www.rsyslog.com/doc/relp.html
+
+package relp
+
+import (
+  "bufio"
+  "io"
+  "strconv"
+  "github.com/scalingdata/syslogparser"
+)
+
+const (
+  CMD_OPEN        = "open"
+  CMD_SYSLOG      = "syslog"
+  CMD_CLOSE       = "close"
+  CMD_RSP         = "rsp"
+  CMD_SERVERCLOSE = "serverclose"
+
+  RELP_VERSION = "0"
+
+  DEFAULT_WINDOW        = 128
+  DEFAULT_MAX_DATA_SIZE = 128 * 1024
+
+  // TXNR = NUMBER ; 1..999999999, wraps back to 1
+  maxTxnr       = 999999999
+  maxTxnrDigits = 9
+  // DATALEN fits an int with that many digits
+  maxDataLenDigits = 9
+  maxCommandLen    = 32
+)
+
+var (
+  ErrInvalidTxnr    = &ProtocolError{"Invalid RELP transaction number"}
+  ErrInvalidCommand = &ProtocolError{"Invalid RELP command"}
+  ErrInvalidDataLen = &ProtocolError{"Invalid RELP data length"}
+  ErrDataTooLarge   = &ProtocolError{"RELP data exceeds the maximum size"}
+  ErrNoTrailer      = &ProtocolError{"Missing RELP frame trailer"}
+  ErrServerClosed   = &ProtocolError{"RELP server closed the session"}
+  ErrNotOpen        = &ProtocolError{"RELP session was not opened"}
+)
+
+type ProtocolError struct {
+  ErrorString string
+}
+
+func (err *ProtocolError) Error() string {
+  return err.ErrorString
+}
+
+// RELP-FRAME = TXNR SP COMMAND SP DATALEN [SP DATA] TRAILER
+type Frame struct {
+  Txnr    int
+  Command string
+  Data    []byte
+}
+
+func ReadFrame(r *bufio.Reader, maxDataSize int) (Frame, error) {
+  var f Frame
+
+  txnr, err := readNumber(r, maxTxnrDigits, ' ', ErrInvalidTxnr)
+  if err != nil {
+    return f, err
+  }
+
+  command, err := readCommand(r)
+  if err != nil {
+    return f, err
+  }
+
+  dataLen, err := readNumber(r, maxDataLenDigits, 0, ErrInvalidDataLen)
+  if err != nil {
+    return f, err
+  }
+
+  if dataLen > maxDataSize {
+    return f, ErrDataTooLarge
+  }
+
+  c, err := r.ReadByte()
+  if err != nil {
+    return f, unexpectedEOF(err)
+  }
+
+  if dataLen > 0 {
+    if c != ' ' {
+      return f, ErrInvalidDataLen
+    }
+
+    f.Data = make([]byte, dataLen)
+    if _, err := io.ReadFull(r, f.Data); err != nil {
+      return f, unexpectedEOF(err)
+    }
+
+    c, err = r.ReadByte()
+    if err != nil {
+      return f, unexpectedEOF(err)
+    }
+  } else if c == ' ' {
+    // Be liberal with senders adding a SP before an empty DATA
+    c, err = r.ReadByte()
+    if err != nil {
+      return f, unexpectedEOF(err)
+    }
+  }
+
+  if c != '\n' {
+    return f, ErrNoTrailer
+  }
+
+  f.Txnr = txnr
+  f.Command = command
+
+  return f, nil
+}
+
+func WriteFrame(w io.Writer, f Frame) error {
+  buff := make([]byte, 0, len(f.Data)+len(f.Command)+24)
+  buff = strconv.AppendInt(buff, int64(f.Txnr), 10)
+  buff = append(buff, ' ')
+  buff = append(buff, f.Command...)
+  buff = append(buff, ' ')
+  buff = strconv.AppendInt(buff, int64(len(f.Data)), 10)
+  if len(f.Data) > 0 {
+    buff = append(buff, ' ')
+    buff = append(buff, f.Data...)
+  }
+  buff = append(buff, '\n')
+
+  _, err := w.Write(buff)
+  return err
+}
+
+/* Reads digits up to the end byte, or up to the first non digit when end
+   is 0, which is then unread. */
+func readNumber(r *bufio.Reader, maxDigits int, end byte, e error) (int, error) {
+  n := 0
+  digits := 0
+
+  for {
+    c, err := r.ReadByte()
+    if err == io.EOF && digits == 0 && end == ' ' {
+      // Clean end of stream between two frames
+      return 0, io.EOF
+    }
+    if err != nil {
+      return 0, unexpectedEOF(err)
+    }
+
+    if !syslogparser.IsDigit(c) {
+      if digits == 0 || (end != 0 && c != end) {
+        return 0, e
+      }
+      if end == 0 {
+        r.UnreadByte()
+      }
+      return n, nil
+    }
+
+    if digits >= maxDigits {
+      return 0, e
+    }
+
+    n = n*10 + int(c-'0')
+    digits++
+  }
+}
+
+// COMMAND = 1*32ALPHA
+func readCommand(r *bufio.Reader) (string, error) {
+  var command []byte
+
+  for {
+    c, err := r.ReadByte()
+    if err != nil {
+      return "", unexpectedEOF(err)
+    }
+
+    if c == ' ' && len(command) > 0 {
+      return string(command), nil
+    }
+
+    if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) || len(command) >= maxCommandLen {
+      return "", ErrInvalidCommand
+    }
+
+    command = append(command, c)
+  }
+}
+
+func unexpectedEOF(err error) error {
+  if err == io.EOF {
+    return io.ErrUnexpectedEOF
+  }
+
+  return err
+}
+
+// RSP-DATA = RSP-CODE [SP HUMANMSG] [LF CMDDATA]
+func parseResponse(data []byte) (int, string) {
+  if len(data) < 3 {
+    return 0, string(data)
+  }
+
+  code, err := strconv.Atoi(string(data[:3]))
+  if err != nil {
+    return 0, string(data)
+  }
+
+  msg := data[3:]
+  if len(msg) > 0 && msg[0] == ' ' {
+    msg = msg[1:]
+  }
+  for i, c := range msg {
+    if c == '\n' {
+      msg = msg[:i]
+      break
+    }
+  }
+
+  return code, string(msg)
+}
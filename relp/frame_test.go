@@ -0,0 +1,95 @@
+package relp
+
+import (
+  "bufio"
+  "bytes"
+  . "github.com/scalingdata/check"
+  "io"
+  "strings"
+  "testing"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type FrameTestSuite struct {
+}
+
+var _ = Suite(&FrameTestSuite{})
+
+func (s *FrameTestSuite) TestReadFrame_Valid(c *C) {
+  stream := "1 open 86 relp_version=0\nrelp_software=librelp,1.2.12,http://librelp.adiscon.com\ncommands=syslog\n" +
+    "2 syslog 5 hello\n" +
+    "3 close 0\n" +
+    "4 rsp 0 \n"
+
+  expected := []Frame{
+    Frame{1, CMD_OPEN, []byte("relp_version=0\nrelp_software=librelp,1.2.12,http://librelp.adiscon.com\ncommands=syslog")},
+    Frame{2, CMD_SYSLOG, []byte("hello")},
+    Frame{3, CMD_CLOSE, nil},
+    Frame{4, CMD_RSP, nil},
+  }
+
+  r := bufio.NewReader(strings.NewReader(stream))
+  for _, e := range expected {
+    f, err := ReadFrame(r, DEFAULT_MAX_DATA_SIZE)
+    c.Assert(err, IsNil)
+    c.Assert(f, DeepEquals, e)
+  }
+
+  _, err := ReadFrame(r, DEFAULT_MAX_DATA_SIZE)
+  c.Assert(err, Equals, io.EOF)
+}
+
+func (s *FrameTestSuite) TestReadFrame_Invalid(c *C) {
+  fixtures := []string{
+    "a syslog 5 hello\n",
+    "1234567890 syslog 5 hello\n",
+    "1 sys-log 5 hello\n",
+    "1 syslog x hello\n",
+    "1 syslog 5hello\n",
+    "1 syslog 5 hello",
+    "1 syslog 5 helloX",
+    "1 syslog 10 hello\n",
+    "1 syslog 200000 hello\n",
+  }
+
+  expected := []error{
+    ErrInvalidTxnr,
+    ErrInvalidTxnr,
+    ErrInvalidCommand,
+    ErrInvalidDataLen,
+    ErrInvalidDataLen,
+    io.ErrUnexpectedEOF,
+    ErrNoTrailer,
+    io.ErrUnexpectedEOF,
+    ErrDataTooLarge,
+  }
+
+  c.Assert(len(fixtures), Equals, len(expected))
+  for i, f := range fixtures {
+    _, err := ReadFrame(bufio.NewReader(strings.NewReader(f)), DEFAULT_MAX_DATA_SIZE)
+    c.Assert(err, Equals, expected[i], Commentf("%q", f))
+  }
+}
+
+func (s *FrameTestSuite) TestWriteFrame(c *C) {
+  var buff bytes.Buffer
+
+  c.Assert(WriteFrame(&buff, Frame{2, CMD_RSP, []byte("200 OK")}), IsNil)
+  c.Assert(WriteFrame(&buff, Frame{3, CMD_CLOSE, nil}), IsNil)
+  c.Assert(buff.String(), Equals, "2 rsp 6 200 OK\n3 close 0\n")
+}
+
+func (s *FrameTestSuite) TestParseResponse(c *C) {
+  code, msg := parseResponse([]byte("200 OK\nrelp_version=0"))
+  c.Assert(code, Equals, 200)
+  c.Assert(msg, Equals, "OK")
+
+  code, msg = parseResponse([]byte("500 disk full"))
+  c.Assert(code, Equals, 500)
+  c.Assert(msg, Equals, "disk full")
+
+  code, _ = parseResponse([]byte("OK"))
+  c.Assert(code, Equals, 0)
+}
@@ -0,0 +1,217 @@
+package relp
+
+import (
+  "bufio"
+  "context"
+  "fmt"
+  "io"
+  log "github.com/scalingdata/log4go"
+  "github.com/scalingdata/syslogparser"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/multiparser"
+  "github.com/scalingdata/syslogparser/server"
+  "net"
+  "sync"
+  "time"
+)
+
+/* Server acknowledges a syslog command only once Handler has returned
+   without error, a failing Handler makes the client keep the message. */
+type Server struct {
+  Handler       server.Handler
+  ParserFactory syslogparser.ParserFactory
+  MaxDataSize   int
+  Software      string
+
+  mu            sync.Mutex
+  listeners     []net.Listener
+  conns         map[net.Conn]struct{}
+  wg            sync.WaitGroup
+  serving       bool
+}
+
+func NewServer(handler server.Handler) *Server {
+  return &Server{
+    Handler:       handler,
+    ParserFactory: multiparser.NewRfcParser,
+    MaxDataSize:   DEFAULT_MAX_DATA_SIZE,
+    Software:      "syslogparser",
+    conns:         make(map[net.Conn]struct{}),
+  }
+}
+
+func (s *Server) Listen(addr string) (net.Addr, error) {
+  l, err := net.Listen("tcp", addr)
+  if err != nil {
+    return nil, err
+  }
+
+  s.AddListener(l)
+  return l.Addr(), nil
+}
+
+/* AddListener serves an already bound listener, eg. a tls.Listener. It must
+   be called before Serve, the server closes l on shutdown. */
+func (s *Server) AddListener(l net.Listener) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.listeners = append(s.listeners, l)
+}
+
+/* Serve blocks until ctx is done. Sessions then get a serverclose once the
+   command in progress has been acknowledged. */
+func (s *Server) Serve(ctx context.Context) error {
+  s.mu.Lock()
+  s.serving = true
+  for _, l := range s.listeners {
+    s.wg.Add(1)
+    go s.acceptLoop(l)
+  }
+  s.mu.Unlock()
+
+  <-ctx.Done()
+
+  s.mu.Lock()
+  s.serving = false
+  for _, l := range s.listeners {
+    l.Close()
+  }
+  for c := range s.conns {
+    // Wakes up the session blocked in a read
+    c.SetReadDeadline(time.Now())
+  }
+  s.mu.Unlock()
+
+  s.wg.Wait()
+
+  return nil
+}
+
+func (s *Server) acceptLoop(l net.Listener) {
+  defer s.wg.Done()
+
+  for {
+    conn, err := l.Accept()
+    if err != nil {
+      if s.isServing() {
+        log.Error("Error accepting on %v: %s", l.Addr(), err)
+      }
+      return
+    }
+
+    if !s.trackConn(conn) {
+      conn.Close()
+      return
+    }
+
+    s.wg.Add(1)
+    go s.session(conn)
+  }
+}
+
+func (s *Server) session(conn net.Conn) {
+  defer s.wg.Done()
+  defer s.untrackConn(conn)
+
+  r := bufio.NewReader(conn)
+  opened := false
+
+  for {
+    f, err := ReadFrame(r, s.MaxDataSize)
+    if err != nil {
+      if !s.isServing() {
+        WriteFrame(conn, Frame{0, CMD_SERVERCLOSE, nil})
+      } else if err != io.EOF {
+        log.Warn("Closing RELP session with %v: %s", conn.RemoteAddr(), err)
+      }
+      return
+    }
+
+    switch {
+    case f.Command == CMD_OPEN:
+      opened = true
+      offers := fmt.Sprintf("200 OK\nrelp_version=%s\nrelp_software=%s\ncommands=%s", RELP_VERSION, s.Software, CMD_SYSLOG)
+      err = s.respond(conn, f.Txnr, offers)
+    case !opened:
+      s.respond(conn, f.Txnr, "500 "+ErrNotOpen.Error())
+      return
+    case f.Command == CMD_SYSLOG:
+      err = s.respond(conn, f.Txnr, s.handle(f.Data, conn))
+    case f.Command == CMD_CLOSE:
+      WriteFrame(conn, Frame{f.Txnr, CMD_RSP, nil})
+      return
+    default:
+      err = s.respond(conn, f.Txnr, "500 Unsupported command "+f.Command)
+    }
+
+    if err != nil {
+      log.Warn("Closing RELP session with %v: %s", conn.RemoteAddr(), err)
+      return
+    }
+  }
+}
+
+func (s *Server) handle(data []byte, conn net.Conn) string {
+  meta := server.Metadata{
+    Peer:       conn.RemoteAddr(),
+    ReceivedAt: time.Now(),
+    Transport:  server.RELP,
+  }
+
+  msg, ok := s.parse(data, meta)
+  if !ok {
+    return "500 Unable to parse message"
+  }
+
+  if err := s.Handler(msg, meta); err != nil {
+    return "500 " + err.Error()
+  }
+
+  return "200 OK"
+}
+
+/* parse runs data through the ParserFactory's parser, ok is false when it
+   panicked on a malformed message, which is then rejected without ending
+   the session. */
+func (s *Server) parse(data []byte, meta server.Metadata) (msg message.IMessage, ok bool) {
+  defer func() {
+    if r := recover(); r != nil {
+      log.Error("Parser panicked on message from %v: %v", meta.Peer, r)
+      msg, ok = nil, false
+    }
+  }()
+
+  p := s.ParserFactory(&data)
+  if err := p.Parse(); err != nil {
+    log.Debug("Unable to parse message due to '%s'", err)
+  }
+
+  return p.Message(), true
+}
+
+func (s *Server) respond(conn net.Conn, txnr int, data string) error {
+  return WriteFrame(conn, Frame{txnr, CMD_RSP, []byte(data)})
+}
+
+func (s *Server) isServing() bool {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.serving
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  if !s.serving {
+    return false
+  }
+  s.conns[conn] = struct{}{}
+  return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  delete(s.conns, conn)
+  conn.Close()
+}
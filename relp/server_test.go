@@ -0,0 +1,191 @@
+package relp
+
+import (
+  "context"
+  "errors"
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/server"
+  "net"
+  "sync"
+  "time"
+)
+
+type ServerTestSuite struct {
+  server   *Server
+  addr     net.Addr
+  cancel   context.CancelFunc
+  done     chan error
+
+  mu       sync.Mutex
+  received []string
+  fail     bool
+}
+
+var _ = Suite(&ServerTestSuite{})
+
+const (
+  msg3164 = "<34>Oct 11 22:14:15 mymachine su: 'su root' failed"
+  msg5424 = "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event"
+)
+
+func (s *ServerTestSuite) SetUpTest(c *C) {
+  s.received = nil
+  s.fail = false
+
+  s.server = NewServer(s.handle)
+  addr, err := s.server.Listen("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.addr = addr
+
+  var ctx context.Context
+  ctx, s.cancel = context.WithCancel(context.Background())
+  s.done = make(chan error, 1)
+  go func() {
+    s.done <- s.server.Serve(ctx)
+  }()
+}
+
+func (s *ServerTestSuite) TearDownTest(c *C) {
+  s.cancel()
+  c.Assert(<-s.done, IsNil)
+}
+
+func (s *ServerTestSuite) TestSendAndAcknowledge(c *C) {
+  client, err := Dial("tcp", s.addr.String(), 2)
+  c.Assert(err, IsNil)
+
+  c.Assert(client.Send([]byte(msg3164)), IsNil)
+  c.Assert(client.Send([]byte(msg5424)), IsNil)
+  c.Assert(client.Send([]byte(msg3164)), IsNil)
+  c.Assert(client.Flush(), IsNil)
+  c.Assert(client.Unacknowledged(), HasLen, 0)
+
+  c.Assert(s.messages(), DeepEquals, []string{"'su root' failed", "An application event", "'su root' failed"})
+
+  c.Assert(client.Close(), IsNil)
+}
+
+func (s *ServerTestSuite) TestSendMessage(c *C) {
+  client, err := Dial("tcp", s.addr.String(), 0)
+  c.Assert(err, IsNil)
+  defer client.Close()
+
+  buff := []byte(msg5424)
+  p := s.server.ParserFactory(&buff)
+  c.Assert(p.Parse(), IsNil)
+
+  c.Assert(client.SendMessage(p.Message()), IsNil)
+  c.Assert(client.Flush(), IsNil)
+  c.Assert(s.messages(), DeepEquals, []string{"An application event"})
+}
+
+func (s *ServerTestSuite) TestHandlerFailureIsNotAcknowledged(c *C) {
+  client, err := Dial("tcp", s.addr.String(), 0)
+  c.Assert(err, IsNil)
+  defer client.Close()
+
+  s.mu.Lock()
+  s.fail = true
+  s.mu.Unlock()
+
+  c.Assert(client.Send([]byte(msg3164)), IsNil)
+  err = client.Flush()
+  c.Assert(err, ErrorMatches, "RELP message rejected: 500 handler failed")
+  c.Assert(client.Unacknowledged(), DeepEquals, [][]byte{[]byte(msg3164)})
+
+  c.Assert(client.Send([]byte(msg3164)), Equals, err)
+}
+
+func (s *ServerTestSuite) TestTruncatedMessage(c *C) {
+  client, err := Dial("tcp", s.addr.String(), 0)
+  c.Assert(err, IsNil)
+  defer client.Close()
+
+  c.Assert(client.Send([]byte("<34>1 2003-10-11T22:14")), IsNil)
+  c.Assert(client.Send([]byte(msg3164)), IsNil)
+  c.Assert(client.Flush(), IsNil)
+  c.Assert(s.messages(), DeepEquals, []string{"", "'su root' failed"})
+}
+
+type panickingParser struct {
+  syslogparser.LogParser
+}
+
+func (p panickingParser) Parse() error {
+  panic("malformed")
+}
+
+func (s *ServerTestSuite) TestParserPanicIsNotAcknowledged(c *C) {
+  s.server.ParserFactory = func(buff *[]byte) syslogparser.LogParser {
+    return panickingParser{}
+  }
+
+  client, err := Dial("tcp", s.addr.String(), 0)
+  c.Assert(err, IsNil)
+  defer client.Close()
+
+  c.Assert(client.Send([]byte(msg3164)), IsNil)
+  err = client.Flush()
+  c.Assert(err, ErrorMatches, "RELP message rejected: 500 Unable to parse message")
+  c.Assert(s.messages(), HasLen, 0)
+}
+
+func (s *ServerTestSuite) TestServerClose(c *C) {
+  client, err := Dial("tcp", s.addr.String(), 0)
+  c.Assert(err, IsNil)
+  defer client.Close()
+
+  c.Assert(client.Send([]byte(msg3164)), IsNil)
+  c.Assert(client.Flush(), IsNil)
+
+  s.cancel()
+  c.Assert(<-s.done, IsNil)
+  s.done <- nil
+
+  select {
+  case <-client.done:
+  case <-time.After(5 * time.Second):
+    c.Fatal("Client did not notice the server close")
+  }
+  c.Assert(client.Flush(), Equals, ErrServerClosed)
+}
+
+func (s *ServerTestSuite) TestSyslogBeforeOpen(c *C) {
+  conn, err := net.Dial("tcp", s.addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  c.Assert(WriteFrame(conn, Frame{1, CMD_SYSLOG, []byte(msg3164)}), IsNil)
+
+  buff := make([]byte, 128)
+  n, err := conn.Read(buff)
+  c.Assert(err, IsNil)
+  c.Assert(string(buff[:n]), Equals, "1 rsp 31 500 RELP session was not opened\n")
+  c.Assert(s.messages(), HasLen, 0)
+}
+
+// -------------
+
+func (s *ServerTestSuite) handle(msg message.IMessage, meta server.Metadata) error {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+
+  if meta.Transport != server.RELP {
+    return errors.New("unexpected transport")
+  }
+
+  if s.fail {
+    return errors.New("handler failed")
+  }
+
+  s.received = append(s.received, msg.Message())
+  return nil
+}
+
+func (s *ServerTestSuite) messages() []string {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.received
+}
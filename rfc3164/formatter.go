@@ -0,0 +1,78 @@
+package rfc3164
+
+import (
+  "bytes"
+  "io"
+  "strconv"
+  "github.com/scalingdata/syslogparser"
+  message "github.com/scalingdata/syslogparser/message"
+  "time"
+)
+
+/* Formatter turns a message.IMessage into an RFC 3164 line:
+   <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: CONTENT */
+type Formatter struct {
+  // Time zone the TIMESTAMP is written in, RFC 3164 has no offset field
+  Location *time.Location
+}
+
+func NewFormatter() *Formatter {
+  return &Formatter{
+    Location: time.Local,
+  }
+}
+
+func (f *Formatter) Write(w io.Writer, msg message.IMessage) error {
+  _, err := w.Write(f.Format(msg))
+  return err
+}
+
+func (f *Formatter) Format(msg message.IMessage) []byte {
+  var buff bytes.Buffer
+
+  pri, _ := syslogparser.ComputePriority(msg.Facility(), msg.Severity())
+
+  ts := msg.TimeStamp()
+  if ts.IsZero() {
+    ts = time.Now()
+  }
+
+  loc := f.Location
+  if loc == nil {
+    loc = time.Local
+  }
+
+  hostname := msg.Hostname()
+  if hostname == "" {
+    hostname = "-"
+  }
+
+  tag := msg.Process()
+  content := msg.Message()
+  if rfcMsg, ok := msg.(IMessage); ok {
+    tag = rfcMsg.Tag()
+    content = rfcMsg.Content()
+  }
+
+  buff.WriteByte(syslogparser.PRI_PART_START)
+  buff.WriteString(strconv.Itoa(pri.P))
+  buff.WriteByte(syslogparser.PRI_PART_END)
+  buff.WriteString(ts.In(loc).Format(time.Stamp))
+  buff.WriteByte(' ')
+  buff.WriteString(hostname)
+  buff.WriteByte(' ')
+
+  if tag != "" {
+    buff.WriteString(tag)
+    if pid := msg.Pid(); pid != "" {
+      buff.WriteByte('[')
+      buff.WriteString(pid)
+      buff.WriteByte(']')
+    }
+    buff.WriteString(": ")
+  }
+
+  buff.WriteString(content)
+
+  return buff.Bytes()
+}
@@ -0,0 +1,61 @@
+package rfc3164
+
+import (
+  . "github.com/scalingdata/check"
+  message "github.com/scalingdata/syslogparser/message"
+  "time"
+)
+
+type Rfc3164FormatterTestSuite struct {
+  originalLocale *time.Location
+}
+
+var _ = Suite(&Rfc3164FormatterTestSuite{})
+
+func (s *Rfc3164FormatterTestSuite) SetUpTest(c *C) {
+  s.originalLocale = time.Local
+  time.Local = time.UTC
+}
+
+func (s *Rfc3164FormatterTestSuite) TearDownTest(c *C) {
+  time.Local = s.originalLocale
+}
+
+func (s *Rfc3164FormatterTestSuite) TestFormat_RoundTrip(c *C) {
+  fixtures := []string{
+    "<94>Jun  6 20:07:15 webtest-mark simlogging[17155]: This is a log.info() message",
+    "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+  }
+
+  f := NewFormatter()
+  for _, fixture := range fixtures {
+    buff := []byte(fixture)
+    p := NewParser(&buff)
+    p.TimeFunction = testDate
+    err := p.Parse()
+    c.Assert(err, IsNil)
+
+    c.Assert(string(f.Format(p.Message())), Equals, fixture)
+  }
+}
+
+func (s *Rfc3164FormatterTestSuite) TestFormat_Location(c *C) {
+  buff := []byte("<94>Jun  6 20:07:15 webtest-mark simlogging[17155]: message")
+  p := NewParser(&buff)
+  p.TimeFunction = testDate
+  c.Assert(p.Parse(), IsNil)
+
+  loc := time.FixedZone("UTC+2", 2*60*60)
+  f := NewFormatter()
+  f.Location = loc
+  c.Assert(string(f.Format(p.Message())), Equals, "<94>Jun  6 22:07:15 webtest-mark simlogging[17155]: message")
+}
+
+func (s *Rfc3164FormatterTestSuite) TestFormat_UnparsableMessage(c *C) {
+  buff := []byte("FOO BAR BAZ")
+  msg := message.NewUnparsableMessage(&buff)
+
+  f := NewFormatter()
+  obtained := string(f.Format(msg))
+  c.Assert(obtained, Equals, "<13>"+msg.TimeStamp().Format(time.Stamp)+" - ")
+}
@@ -5,6 +5,21 @@ import (
   "time"
 )
 
+/* IMessage exposes the RFC 3164 specific fields on top of the common
+   message.IMessage, type assert a message.IMessage to get to them. */
+type IMessage interface {
+  message.IMessage
+  Tag() string
+  Content() string
+  // Repairs made to parse the message, see Parser.Lenient
+  Warnings() []Warning
+  // The timestamp had no year, it was inferred, see Parser.ReferenceTime
+  YearInferred() bool
+  TimestampInfo() message.TimestampInfo
+  // Charset guessed for the message, see Parser.DetectCharset
+  DetectedCharset() string
+}
+
 type Rfc3164Message struct {
   rawMsg *[]byte
   ts time.Time
@@ -14,6 +29,10 @@ type Rfc3164Message struct {
   process string
   hostname string
   message string
+  warnings []Warning
+  yearInferred bool
+  timestampInfo message.TimestampInfo
+  detectedCharset string
 }
 
 func (self Rfc3164Message) RawMessage() *[]byte { 
@@ -48,3 +67,26 @@ func (self Rfc3164Message) Message() string {
   return self.message 
 }
 
+func (self Rfc3164Message) Tag() string {
+  return self.process
+}
+
+func (self Rfc3164Message) Content() string {
+  return self.message
+}
+
+func (self Rfc3164Message) Warnings() []Warning {
+  return self.warnings
+}
+
+func (self Rfc3164Message) YearInferred() bool {
+  return self.yearInferred
+}
+
+func (self Rfc3164Message) TimestampInfo() message.TimestampInfo {
+  return self.timestampInfo
+}
+
+func (self Rfc3164Message) DetectedCharset() string {
+  return self.detectedCharset
+}
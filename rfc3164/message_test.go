@@ -48,6 +48,20 @@ func (s *Rfc3164MessageTestSuite) TestMessageFromRfc3164(c *C) {
   c.Assert("17155", Equals, msg.Pid())
 }
 
+func (s *Rfc3164MessageTestSuite) TestMessageRfc3164Fields(c *C) {
+  parser := NewParser(&sampleRfc3164Log)
+  parser.TimeFunction = testDate
+  err := parser.Parse()
+  if nil != err {
+    c.Fatal(err)
+  }
+  var msg message.IMessage = parser.Message()
+  rfcMsg, ok := msg.(IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert("simlogging", Equals, rfcMsg.Tag())
+  c.Assert("This is a log.info() message", Equals, rfcMsg.Content())
+}
+
 func (s *Rfc3164MessageTestSuite) TestMessageCantParseMessage(c *C) {
   badMsg := []byte("FOO BAR BAZ")
   parser := NewParser(&badMsg)
@@ -2,12 +2,17 @@ package rfc3164
 
 import (
   "bytes"
-  "math"
   "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/charset"
+  "github.com/scalingdata/syslogparser/internal/zerocopy"
   message "github.com/scalingdata/syslogparser/message"
+  "strings"
   "time"
 )
 
+// Reported in syslogparser.PositionError
+const FORMAT = "RFC3164"
+
 type Parser struct {
   buff     []byte
   cursor   int
@@ -17,14 +22,82 @@ type Parser struct {
   header   header
   message  rfc3164message
   parseSuccessful bool
+  // Field being parsed, reported on errors
+  field    string
+  warnings []Warning
+  // Fields share buff instead of copying it, see SetZeroCopy
+  zeroCopy bool
+  // Set by parseTimestamp when the timestamp has no year
+  yearInferred bool
+  // Set by parseTimestamp when the timestamp has its own time zone
+  timestampZoned bool
+  timestampInfo message.TimestampInfo
   TimeFunction TimeNow
+  /* Lenient makes Parse repair what it can of a broken message instead of
+     failing, each repair is listed in Warnings. */
+  Lenient  bool
+  // TIMESTAMP layouts tried in order, DefaultLayouts when nil
+  Layouts  []string
+  // Time zone of the timestamps without one or with one missing from Zones, time.Local when nil
+  Location *time.Location
+  // Time zones by the abbreviation devices print, eg. "CET", UTC and GMT being known
+  Zones map[string]*time.Location
+  /* LocationResolver gives the time zone of the timestamps sent by a host,
+     it returns nil for hosts in Location. */
+  LocationResolver LocationResolver
+  /* Timestamps without a year get the year putting them closest to
+     ReferenceTime, or to the time given by TimeFunction when it is zero. Set
+     it to the time of the archive when replaying old logs. */
+  ReferenceTime time.Time
+  /* Decoder converts the hostname, TAG and content from the charset of
+     the senders to UTF-8, they are kept as is when nil. */
+  Decoder syslogparser.CharsetDecoder
+  // DecoderResolver gives the decoder of a host, it returns nil for hosts using Decoder
+  DecoderResolver DecoderResolver
+  /* DetectCharset guesses the charset of messages when no decoder is set,
+     see charset.Detect, and converts them to UTF-8. */
+  DetectCharset bool
+  // Set by decode when DetectCharset is on
+  detectedCharset string
+}
+
+/* DefaultLayouts are the TIMESTAMP layouts of the RFC and of common devices,
+   the seconds can be followed by a fraction in all of them, eg. .123 */
+var DefaultLayouts = []string{
+  // The RFC's, optionally followed by a year
+  "Jan _2 15:04:05 2006",
+  // Cisco IOS, the star tells the clock is not synchronized
+  "Jan _2 15:04:05 MST:",
+  "*Jan _2 15:04:05 MST:",
+  "Jan _2 15:04:05",
+  // rsyslog's high precision template, ESXi
+  time.RFC3339,
+  // Juniper
+  "Jan _2 2006 15:04:05",
 }
 
 type TimeNow func() time.Time
 
+type LocationResolver func(hostname string) *time.Location
+
+type DecoderResolver func(hostname string) syslogparser.CharsetDecoder
+
+/* Warning explains a repair made in lenient mode : Err is the problem
+   found, as a *syslogparser.PositionError, and Repair what was done about it. */
+type Warning struct {
+  Err    error
+  Repair string
+}
+
+func (w Warning) String() string {
+  return w.Err.Error() + ", " + w.Repair
+}
+
 type header struct {
   timestamp time.Time
   hostname  string
+  yearInferred bool
+  timestampInfo message.TimestampInfo
 }
 
 type rfc3164message struct {
@@ -43,26 +116,125 @@ func NewParser(buff *[]byte) *Parser {
   }
 }
 
+// Reset prepares the parser for a new message, keeping its settings
+func (p *Parser) Reset(buff *[]byte) {
+  p.buff = *buff
+  p.cursor = 0
+  p.l = len(*buff)
+  p.priority = syslogparser.Priority{}
+  p.version = 0
+  p.header = header{}
+  p.message = rfc3164message{}
+  p.parseSuccessful = false
+  p.field = ""
+  p.warnings = nil
+  p.yearInferred = false
+  p.timestampZoned = false
+  p.timestampInfo = message.TimestampInfo{}
+  p.detectedCharset = ""
+}
+
+/* SetZeroCopy makes the parsed fields share the memory of the buffer
+   instead of copying it, the buffer must then not be modified while they
+   are in use. */
+func (p *Parser) SetZeroCopy(zeroCopy bool) {
+  p.zeroCopy = zeroCopy
+}
+
+/* ParseInto parses buff into msg without allocating : the parser and msg
+   can be reused for every message. The strings of msg share the memory of
+   buff, so buff must not be modified while msg is in use. */
+func (p *Parser) ParseInto(buff *[]byte, msg *Rfc3164Message) error {
+  p.Reset(buff)
+  zeroCopy := p.zeroCopy
+  p.zeroCopy = true
+
+  err := p.Parse()
+  p.zeroCopy = zeroCopy
+  if err != nil {
+    *msg = Rfc3164Message{rawMsg: buff}
+    return err
+  }
+
+  p.fillMessage(msg, buff)
+  return nil
+}
+
+/* Detect tells how likely buff is an RFC 3164 message from its PRI and the
+   shape of its TIMESTAMP, LIKELY when it starts like one of DefaultLayouts.
+   Messages without PRI only parse in lenient mode. */
+func Detect(buff []byte) syslogparser.Confidence {
+  cursor := 0
+
+  if _, err := syslogparser.ParsePriority(buff, &cursor, len(buff)); err != nil {
+    return syslogparser.NO_MATCH
+  }
+
+  ts := buff[cursor:]
+  if len(ts) > 0 && ts[0] == '*' {
+    ts = ts[1:]
+  }
+
+  if len(ts) > 3 && isMonth(ts[:3]) && ts[3] == ' ' {
+    return syslogparser.LIKELY
+  }
+
+  // RFC 3339
+  if syslogparser.StartsWithFullDate(ts) && len(ts) > 10 && ts[10] == 'T' {
+    return syslogparser.LIKELY
+  }
+
+  return syslogparser.UNLIKELY
+}
+
 func (p *Parser) Parse() error {
+  p.field = syslogparser.FIELD_PRI
   pri, err := p.parsePriority()
   if err != nil {
-    return err
+    if !p.Lenient {
+      return p.parseError(err)
+    }
+
+    // https://tools.ietf.org/html/rfc3164#section-4.3.3
+    pri, _ = syslogparser.ComputePriority(message.User, message.Notice)
+    p.warn(p.cursor, err, "using the default priority")
   }
 
+  start := p.cursor
   hdr, err := p.parseHeader()
   if err != nil {
-    return err
+    if !p.Lenient {
+      return p.parseError(err)
+    }
+
+    /* Without a valid TIMESTAMP the HEADER can not be told apart from the
+       MSG, so the whole of it is kept as MSG as described in
+       https://tools.ietf.org/html/rfc3164#section-4.3.2 */
+    p.warn(start, err, "using the receive time and no hostname")
+    hdr = header{
+      timestamp:     p.TimeFunction().UTC(),
+      timestampInfo: message.TimestampInfo{Absent: true},
+    }
+    p.cursor = start
+  } else {
+    p.cursor++
   }
 
-  p.cursor++
- 
+  p.field = syslogparser.FIELD_TAG
+
   if p.cursor >= p.l {
-    return syslogparser.ErrEOL
+    if !p.Lenient {
+      return p.parseError(syslogparser.ErrEOL)
+    }
+
+    // The HEADER may end the message without the space following it
+    p.cursor = p.l
+    p.warn(p.cursor, syslogparser.ErrEOL, "using an empty MSG")
   }
 
   msg, err := p.parsemessage()
   if err != syslogparser.ErrEOL {
-    return err
+    return p.parseError(err)
   }
 
   p.priority = pri
@@ -70,6 +242,10 @@ func (p *Parser) Parse() error {
   p.header = hdr
   p.message = msg
 
+  if err := p.decode(); err != nil {
+    return p.parseError(err)
+  }
+
   p.parseSuccessful = true
   return nil
 }
@@ -87,21 +263,121 @@ func (p *Parser) Dump() syslogparser.LogParts {
   }
 }
 
+// Warnings lists the repairs made by the last Parse in lenient mode
+func (p *Parser) Warnings() []Warning {
+  return p.warnings
+}
+
 func (p *Parser) Message() message.IMessage {
   if ! p.parseSuccessful {
     return message.NewUnparsableMessage(&p.buff)
   } else {
-    return &Rfc3164Message{
-      rawMsg: &p.buff,
-      ts: p.header.timestamp,
-      pid: p.message.procId,
-      facility: message.Facility(p.priority.F.Value),
-      severity: message.Severity(p.priority.S.Value),
-      process: p.message.tag,
-      hostname: p.header.hostname,
-      message: p.message.content,
+    msg := &Rfc3164Message{}
+    p.fillMessage(msg, &p.buff)
+    return msg
+  }
+}
+
+func (p *Parser) fillMessage(msg *Rfc3164Message, rawMsg *[]byte) {
+  *msg = Rfc3164Message{
+    rawMsg: rawMsg,
+    ts: p.header.timestamp,
+    pid: p.message.procId,
+    facility: message.Facility(p.priority.F.Value),
+    severity: message.Severity(p.priority.S.Value),
+    process: p.message.tag,
+    hostname: p.header.hostname,
+    message: p.message.content,
+    warnings: p.warnings,
+    yearInferred: p.header.yearInferred,
+    timestampInfo: p.header.timestampInfo,
+    detectedCharset: p.detectedCharset,
+  }
+}
+
+func (p *Parser) str(b []byte) string {
+  if p.zeroCopy {
+    return zerocopy.String(b)
+  }
+
+  return string(b)
+}
+
+func (p *Parser) warn(offset int, err error, repair string) {
+  p.warnings = append(p.warnings, Warning{
+    Err:    syslogparser.NewPositionError(FORMAT, p.field, offset, err),
+    Repair: repair,
+  })
+}
+
+// parseError locates err at the field and position the parser stopped at
+func (p *Parser) parseError(err error) error {
+  return syslogparser.NewPositionError(FORMAT, p.field, p.cursor, err)
+}
+
+func (p *Parser) location() *time.Location {
+  if p.Location != nil {
+    return p.Location
+  }
+
+  return time.Local
+}
+
+// referenceTime is the time the year of timestamps is inferred from
+func (p *Parser) referenceTime() time.Time {
+  if !p.ReferenceTime.IsZero() {
+    return p.ReferenceTime
+  }
+
+  return p.TimeFunction()
+}
+
+// decoder gives the decoder of the messages sent by hostname, nil if none
+func (p *Parser) decoder(hostname string) (syslogparser.CharsetDecoder, error) {
+  if p.DecoderResolver != nil {
+    if decoder := p.DecoderResolver(hostname); decoder != nil {
+      return decoder, nil
+    }
+  }
+
+  if p.Decoder != nil || !p.DetectCharset {
+    return p.Decoder, nil
+  }
+
+  p.detectedCharset = charset.Detect(p.buff)
+  return charset.Decoder(p.detectedCharset)
+}
+
+// decode converts the hostname, TAG and content to UTF-8, see Decoder
+func (p *Parser) decode() error {
+  decoder, err := p.decoder(p.header.hostname)
+  if decoder == nil || err != nil {
+    return err
+  }
+
+  fields := []struct {
+    name  string
+    value *string
+  }{
+    {syslogparser.FIELD_HOSTNAME, &p.header.hostname},
+    {syslogparser.FIELD_TAG, &p.message.tag},
+    {syslogparser.FIELD_MSG, &p.message.content},
+  }
+
+  for _, f := range fields {
+    if isASCII(*f.value) {
+      continue
     }
+
+    p.field = f.name
+    decoded, err := decoder([]byte(*f.value))
+    if err != nil {
+      return err
+    }
+    *f.value = decoded
   }
+
+  return nil
 }
 
 func (p *Parser) parsePriority() (syslogparser.Priority, error) {
@@ -112,18 +388,30 @@ func (p *Parser) parseHeader() (header, error) {
   hdr := header{}
   var err error
 
+  p.field = syslogparser.FIELD_TIMESTAMP
   ts, err := p.parseTimestamp()
   if err != nil {
     return hdr, err
   }
 
+  p.field = syslogparser.FIELD_HOSTNAME
   hostname, err := p.parseHostname()
   if err != nil {
     return hdr, err
   }
 
+  if p.LocationResolver != nil && !p.timestampZoned {
+    if loc := p.LocationResolver(hostname); loc != nil {
+      ts = inLocation(ts, p.location(), loc)
+      _, p.timestampInfo.Offset = ts.Zone()
+      ts = ts.UTC()
+    }
+  }
+
   hdr.timestamp = ts
   hdr.hostname = hostname
+  hdr.yearInferred = p.yearInferred
+  hdr.timestampInfo = p.timestampInfo
 
   return hdr, nil
 }
@@ -132,12 +420,14 @@ func (p *Parser) parsemessage() (rfc3164message, error) {
   msg := rfc3164message{}
   var err error
 
+  p.field = syslogparser.FIELD_TAG
   tag, err := p.parseTag()
   if err != nil {
     return msg, err
   }
   msg.tag = tag
 
+  p.field = syslogparser.FIELD_MSG
   pid, content, err := p.parseContent()
   if err != syslogparser.ErrEOL {
     return msg, err
@@ -152,70 +442,113 @@ func (p *Parser) parsemessage() (rfc3164message, error) {
 func (p *Parser) parseTimestamp() (time.Time, error) {
   var ts time.Time
   var err error
-  var tsFmtLen int
-  var sub []byte
 
-  tsFmts := []string{
-    "Jan 02 15:04:05 2006",
-    "Jan  2 15:04:05 2006",
-    "Jan 02 15:04:05",
-    "Jan  2 15:04:05",
+  layouts := p.Layouts
+  if layouts == nil {
+    layouts = DefaultLayouts
   }
 
-  found := false
-  for i, tsFmt := range tsFmts {
-    tsFmtLen = len(tsFmt)
+  for _, layout := range layouts {
+    end, ok := p.timestampEnd(layout)
+    if !ok {
+      continue
+    }
 
-    if p.cursor+tsFmtLen > p.l {
+    sub := p.buff[p.cursor:end]
+
+    /* The value is not copied : time.Parse keeps it in its errors, which are
+       dropped, and in the zone of times with an abbreviation it does not
+       know, which resolveZone replaces. */
+    ts, err = time.ParseInLocation(layout, zerocopy.String(sub), p.location())
+    if err != nil {
       continue
     }
 
-    sub = p.buff[p.cursor : tsFmtLen+p.cursor]
-
-    // Only test the date pattern if the next character is a space (or the end of the message)
-    // This prevents us picking up the first 4 numbers of a hostname as a year
-    if p.cursor+tsFmtLen == p.l || p.buff[p.cursor + tsFmtLen] == ' ' {
-      ts, err = time.ParseInLocation(tsFmt, string(sub), time.Local)
-      if err == nil {
-	// The first two patterns have a year component - check that it's "reasonable"
-	// (1999 < year < 2100).
-	if i < 2  && ! ( ts.Year() > 1999 && ts.Year() < 2100) {
-          continue
-	}
-        /* Set Year on the Timestamp before converting to UTC so that time zone and
-           DST settings (which are year dependent) can be properly assessed in the
-           conversion. */
-        p.fixTimestampIfNeeded(&ts)
-        ts = ts.UTC()
-        found = true
-        break
-      }
+    // Check that the year, when there is one, is "reasonable" (1999 < year < 2100)
+    if strings.Contains(layout, "2006") && !(ts.Year() > 1999 && ts.Year() < 2100) {
+      continue
     }
-  }
 
-  if !found {
-    p.cursor = tsFmtLen
+    /* Set Year on the Timestamp before converting to UTC so that time zone and
+       DST settings (which are year dependent) can be properly assessed in the
+       conversion. */
+    zoned := strings.Contains(layout, "Z07") || strings.Contains(layout, "-07")
+    if strings.Contains(layout, "MST") {
+      zoned = p.resolveZone(&ts)
+    }
+    p.yearInferred = p.fixTimestampIfNeeded(&ts)
+    p.timestampZoned = zoned
+    _, offset := ts.Zone()
+    p.timestampInfo = message.TimestampInfo{
+      Raw:       p.str(sub),
+      Precision: fractionDigits(sub),
+      Offset:    offset,
+    }
+    p.cursor = end
 
-    // XXX : If the timestamp is invalid we try to push the cursor one byte
-    // XXX : further, in case it is a space
     if (p.cursor < p.l) && (p.buff[p.cursor] == ' ') {
       p.cursor++
     }
 
-    return ts, syslogparser.ErrTimestampUnknownFormat
+    return ts.UTC(), nil
   }
 
-  p.cursor += tsFmtLen
+  return time.Time{}, syslogparser.ErrTimestampUnknownFormat
+}
+
+/* timestampEnd returns the end of the timestamp at the cursor if it is laid
+   out as layout : it has as many fields separated by spaces as layout. ok is
+   false when the fields can not match, saving a call to time.Parse. */
+func (p *Parser) timestampEnd(layout string) (int, bool) {
+  end := p.cursor
+  i := 0
 
-  if (p.cursor < p.l) && (p.buff[p.cursor] == ' ') {
-    p.cursor++
+  for i < len(layout) {
+    for i < len(layout) && layout[i] == ' ' {
+      i++
+    }
+    for end < p.l && p.buff[end] == ' ' {
+      end++
+    }
+
+    layoutFrom, from := i, end
+    for i < len(layout) && layout[i] != ' ' {
+      i++
+    }
+    for end < p.l && p.buff[end] != ' ' {
+      end++
+    }
+
+    if from == end {
+      return 0, false
+    }
+
+    // Punctuation around the fields, eg. "*" and ":" for Cisco, must match
+    first, last := layout[layoutFrom], layout[i-1]
+    if (isPunct(first) && first != p.buff[from]) || (isPunct(last) && last != p.buff[end-1]) {
+      return 0, false
+    }
+
+    // Keep hostnames starting with digits from being taken for a year
+    if layout[layoutFrom:i] == "2006" {
+      if end-from != 4 {
+        return 0, false
+      }
+
+      for _, c := range p.buff[from:end] {
+        if !syslogparser.IsDigit(c) {
+          return 0, false
+        }
+      }
+    }
   }
 
-  return ts, nil
+  return end, true
 }
 
 func (p *Parser) parseHostname() (string, error) {
-  return syslogparser.ParseHostname(p.buff, &p.cursor, p.l)
+  hostname, err := syslogparser.ParseHostnameBytes(p.buff, &p.cursor, p.l)
+  return p.str(hostname), err
 }
 
 // http://tools.ietf.org/html/rfc3164#section-4.1.3
@@ -233,12 +566,12 @@ func (p *Parser) parseTag() (string, error) {
     } else {
       tag := p.buff[p.cursor:p.cursor+i]
       p.cursor = p.cursor+i
-      return string(tag), nil
+      return p.str(tag), nil
     }
   }
   tag := p.buff[p.cursor:p.cursor+i]
   p.cursor = p.cursor+i
-  return string(tag), nil
+  return p.str(tag), nil
 }
 
 func (p *Parser) parseContent() (string, string, error) {
@@ -252,18 +585,14 @@ func (p *Parser) parseContent() (string, string, error) {
   }
 
   /* Trim any padding that might appear after the pid */
-  curChar := p.buff[p.cursor]
-  for (':' == curChar || ' ' == curChar) &&  (p.cursor < p.l) {
+  for p.cursor < p.l && (':' == p.buff[p.cursor] || ' ' == p.buff[p.cursor]) {
     p.cursor++
-    if p.cursor < p.l {
-      curChar = p.buff[p.cursor]
-    }
   }
 
   content := bytes.Trim(p.buff[p.cursor:p.l], " ")
   p.cursor = p.l
 
-  return pid, string(content), syslogparser.ErrEOL
+  return pid, p.str(content), syslogparser.ErrEOL
 }
 
 func (p *Parser) parsePid() (string, error) {
@@ -272,13 +601,9 @@ func (p *Parser) parsePid() (string, error) {
   } else {
     /* Walk past our initial '[' char until we find a non-numeric
        value or we hit the end of the buffer. */
-    i := p.cursor + 1;
-    curChar := p.buff[i]; 
-    for (curChar >= '0' && curChar <= '9') && i < p.l {
+    i := p.cursor + 1
+    for i < p.l && syslogparser.IsDigit(p.buff[i]) {
       i++
-      if i < p.l {
-        curChar = p.buff[i]
-      }
     }
     if i >= p.l {
       /* We got to the end of the buffer, and no closing bracket found */
@@ -287,7 +612,7 @@ func (p *Parser) parsePid() (string, error) {
       /* Found closing bracket, pull out the pid */
       pid := p.buff[p.cursor+1:i]
       p.cursor = i+1
-      return string(pid), nil
+      return p.str(pid), nil
     } else {
       /* We found a non-numeric value that wasn't the ']', not a pid */
       return "", nil
@@ -295,37 +620,85 @@ func (p *Parser) parsePid() (string, error) {
   }
 }
 
-func (p *Parser) fixTimestampIfNeeded(ts *time.Time) {
+/* resolveZone moves ts, parsed with a zone abbreviation, to the time zone
+   of the abbreviation in Zones and tells if it is known : time.Parse gives
+   the abbreviations it does not know a zero offset, so the timestamps of
+   those are taken in Location, or the zone of LocationResolver, instead.
+   The zone built by time.Parse is always dropped, its name sharing the
+   memory of the buffer. */
+func (p *Parser) resolveZone(ts *time.Time) bool {
+  zone, _ := ts.Zone()
+  loc, known := p.Zones[zone]
+  switch {
+  case zone == "UTC" || zone == "GMT":
+    loc, known = time.UTC, true
+  case !known || loc == nil:
+    loc, known = p.location(), false
+  }
+
+  *ts = time.Date(ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(),
+    ts.Nanosecond(), loc)
+  return known
+}
+
+// fixTimestampIfNeeded sets the year of ts when it has none, and tells so
+func (p *Parser) fixTimestampIfNeeded(ts *time.Time) bool {
   /* Don't clobber a valid year */
   if ts.Year() > 0 {
-    return
-  }
-
-  /* Chanegable for testing, should be time.Now in regular use */
-  now := p.TimeFunction()
-  
-  /* Compute the event timestamp this year, next year and last year.
-     This covers cases where an event crosses December->January, and
-     the sender's clock is ahead of ours and the event goes January-> December */
-
-  newTs := time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
-    ts.Second(), ts.Nanosecond(), ts.Location())
-  lastYearTs := time.Date(now.Year()-1, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
-    ts.Second(), ts.Nanosecond(), ts.Location())
-  nextYearTs := time.Date(now.Year()+1, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
-    ts.Second(), ts.Nanosecond(), ts.Location())
-
-  /* Take the time in seconds between the current date and each candidate timestamp */
-  lastYearDiff := float64(now.Unix() - lastYearTs.Unix())
-  nextYearDiff := float64(nextYearTs.Unix() - now.Unix())
-  thisYearDiff := math.Abs(float64(now.Unix() - newTs.Unix()))
-
-  /* Set the event timestamp to the candidate which is closest to today's date */
-  if lastYearDiff < nextYearDiff && lastYearDiff < thisYearDiff {
-    *ts = lastYearTs
-  } else if nextYearDiff < lastYearDiff && nextYearDiff < thisYearDiff {
-    *ts = nextYearTs
-  } else {
-    *ts = newTs
+    return false
   }
+
+  *ts = syslogparser.InferYear(*ts, p.referenceTime())
+  return true
+}
+
+// fractionDigits counts the digits of the fraction following the seconds in ts
+func fractionDigits(ts []byte) int {
+  for i := 3; i < len(ts); i++ {
+    if (ts[i] == '.' || ts[i] == ',') && ts[i-3] == ':' &&
+      syslogparser.IsDigit(ts[i-2]) && syslogparser.IsDigit(ts[i-1]) {
+      digits := 0
+      for i+1+digits < len(ts) && syslogparser.IsDigit(ts[i+1+digits]) {
+        digits++
+      }
+      return digits
+    }
+  }
+
+  return 0
+}
+
+var months = [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+func isMonth(b []byte) bool {
+  for _, m := range months {
+    if string(b) == m {
+      return true
+    }
+  }
+
+  return false
+}
+
+func isASCII(s string) bool {
+  for i := 0; i < len(s); i++ {
+    if s[i] >= 0x80 {
+      return false
+    }
+  }
+
+  return true
+}
+
+// isPunct tells if c is a literal of layouts that messages must hold as is
+func isPunct(c byte) bool {
+  return c == '*' || c == ':' || c == '[' || c == ']'
+}
+
+// inLocation gives the time showing the same wall clock as ts in from, in to
+func inLocation(ts time.Time, from *time.Location, to *time.Location) time.Time {
+  wall := ts.In(from)
+
+  return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(),
+    wall.Second(), wall.Nanosecond(), to)
 }
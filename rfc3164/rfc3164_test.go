@@ -2,7 +2,10 @@ package rfc3164
 
 import (
   "bytes"
+  "errors"
   "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/charset"
+  "github.com/scalingdata/syslogparser/message"
   "strings"
   . "github.com/scalingdata/check"
   "testing"
@@ -18,10 +21,6 @@ type Rfc3164TestSuite struct {
 
 var (
   _ = Suite(&Rfc3164TestSuite{})
-
-  // XXX : corresponds to the length of the last tried timestamp format
-  // XXX : Jan  2 15:04:05
-  lastTriedTimestampLen = 15
   octTestDate = func() time.Time { return time.Date(2015, time.October, 12, 0, 0, 0, 0, time.Now().Location()) } 
 )
 
@@ -113,6 +112,145 @@ func (s *Rfc3164TestSuite) TestParser_SlashTag(c *C) {
   c.Assert(obtained, DeepEquals, expected)
 }
 
+func (s *Rfc3164TestSuite) TestParser_Errors(c *C) {
+  fixtures := []struct {
+    buff   string
+    field  string
+    offset int
+    cause  error
+  }{
+    {"Oct 11 22:14:15 mymachine foo", syslogparser.FIELD_PRI, 0, syslogparser.ErrPriorityNoStart},
+    {"<34>Oct 11 22:14:15 mymachine", syslogparser.FIELD_TAG, 30, syslogparser.ErrEOL},
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    err := NewParser(&buff).Parse()
+    c.Assert(errors.Is(err, f.cause), Equals, true, Commentf("%q: %v", f.buff, err))
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(err, &parseErr), Equals, true)
+    c.Assert(parseErr.Format, Equals, FORMAT)
+    c.Assert(parseErr.Field, Equals, f.field, Commentf("%q", f.buff))
+    c.Assert(parseErr.Offset, Equals, f.offset, Commentf("%q", f.buff))
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParser_Lenient(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected syslogparser.LogParts
+    field    string
+    offset   int
+    cause    error
+  }{
+    {
+      "<34>Oct 32 22:14:15 mymachine su: 'su root' failed",
+      syslogparser.LogParts{
+        "timestamp": octTestDate().UTC(),
+        "hostname":  "",
+        "tag":       "Oct",
+        "content":   "32 22:14:15 mymachine su: 'su root' failed",
+        "priority":  34,
+        "facility":  4,
+        "severity":  2,
+        "proc_id":   "",
+      },
+      syslogparser.FIELD_TIMESTAMP, 4, syslogparser.ErrTimestampUnknownFormat,
+    },
+    {
+      "Oct 11 22:14:15 mymachine su: 'su root' failed",
+      syslogparser.LogParts{
+        "timestamp": time.Date(2015, time.October, 11, 22, 14, 15, 0, time.UTC),
+        "hostname":  "mymachine",
+        "tag":       "su",
+        "content":   "'su root' failed",
+        "priority":  13,
+        "facility":  1,
+        "severity":  5,
+        "proc_id":   "",
+      },
+      syslogparser.FIELD_PRI, 0, syslogparser.ErrPriorityNoStart,
+    },
+    {
+      "<34>Oct 11 22:14:15 mymachine",
+      syslogparser.LogParts{
+        "timestamp": time.Date(2015, time.October, 11, 22, 14, 15, 0, time.UTC),
+        "hostname":  "mymachine",
+        "tag":       "",
+        "content":   "",
+        "priority":  34,
+        "facility":  4,
+        "severity":  2,
+        "proc_id":   "",
+      },
+      syslogparser.FIELD_TAG, 29, syslogparser.ErrEOL,
+    },
+    {
+      "<34>Oct 11 22:14:15 myma",
+      syslogparser.LogParts{
+        "timestamp": time.Date(2015, time.October, 11, 22, 14, 15, 0, time.UTC),
+        "hostname":  "myma",
+        "tag":       "",
+        "content":   "",
+        "priority":  34,
+        "facility":  4,
+        "severity":  2,
+        "proc_id":   "",
+      },
+      syslogparser.FIELD_TAG, 24, syslogparser.ErrEOL,
+    },
+    {
+      "<28>Mar  1 2024 12:00:00",
+      syslogparser.LogParts{
+        "timestamp": time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC),
+        "hostname":  "",
+        "tag":       "",
+        "content":   "",
+        "priority":  28,
+        "facility":  3,
+        "severity":  4,
+        "proc_id":   "",
+      },
+      syslogparser.FIELD_TAG, 24, syslogparser.ErrEOL,
+    },
+  }
+
+  for _, f := range fixtures {
+    // Without spare capacity, reading past the end panics
+    buff := []byte(f.buff)
+    buff = buff[:len(buff):len(buff)]
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+    p.Lenient = true
+
+    c.Assert(p.Parse(), IsNil)
+    c.Assert(p.Dump(), DeepEquals, f.expected)
+
+    warnings := p.Message().(IMessage).Warnings()
+    c.Assert(warnings, HasLen, 1)
+    c.Assert(errors.Is(warnings[0].Err, f.cause), Equals, true)
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(warnings[0].Err, &parseErr), Equals, true)
+    c.Assert(parseErr.Field, Equals, f.field)
+    c.Assert(parseErr.Offset, Equals, f.offset)
+
+    absent := p.Message().(IMessage).TimestampInfo().Absent
+    c.Assert(absent, Equals, f.field == syslogparser.FIELD_TIMESTAMP)
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParser_LenientValidMessage(c *C) {
+  buff := []byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+  p.Lenient = true
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Warnings(), HasLen, 0)
+}
+
 func (s *Rfc3164TestSuite) TestParse_TimestampWithYear(c *C) {
   // Test the date pattern where there's a year and a two-digit day
   buff := []byte("<22>Jan 24 14:03:00 2015 HOSTNAME SomeProgram: LogMessage")
@@ -237,8 +375,10 @@ func (s *Rfc3164TestSuite) TestParse_HostWithFourDigitPrefix(c *C) {
 func (s *Rfc3164TestSuite) TestParseHeader_Valid(c *C) {
   buff := []byte("Oct 11 22:14:15 mymachine ")
   hdr := header{
-    timestamp: time.Date(2015, time.October, 11, 22, 14, 15, 0, time.UTC),
-    hostname:  "mymachine",
+    timestamp:     time.Date(2015, time.October, 11, 22, 14, 15, 0, time.UTC),
+    timestampInfo: message.TimestampInfo{Raw: "Oct 11 22:14:15"},
+    hostname:      "mymachine",
+    yearInferred:  true,
   }
 
   s.assertRfc3164Header(c, hdr, buff, 25, nil)
@@ -253,18 +393,91 @@ func (s *Rfc3164TestSuite) TestParseHeader_UseLocalTimezone(c *C) {
 
   buff := []byte("Oct 11 16:14:15 mymachine ")
   hdr := header{
-    timestamp: time.Date(2015, time.October, 11, 21, 14, 15, 0, time.UTC),
-    hostname:  "mymachine",
+    timestamp:     time.Date(2015, time.October, 11, 21, 14, 15, 0, time.UTC),
+    timestampInfo: message.TimestampInfo{Raw: "Oct 11 16:14:15", Offset: -5 * 3600},
+    hostname:      "mymachine",
+    yearInferred:  true,
   }
 
   s.assertRfc3164Header(c, hdr, buff, 25, nil)
 }
 
+func (s *Rfc3164TestSuite) TestParseHeader_Location(c *C) {
+  loc, err := time.LoadLocation("EST")
+  if nil != err {
+    c.Fatal(err)
+  }
+
+  buff := []byte("Oct 11 16:14:15 mymachine ")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+  p.Location = loc
+
+  obtained, err := p.parseHeader()
+  c.Assert(err, IsNil)
+  c.Assert(obtained.timestamp, Equals, time.Date(2015, time.October, 11, 21, 14, 15, 0, time.UTC))
+}
+
+func (s *Rfc3164TestSuite) TestParseHeader_LocationResolver(c *C) {
+  loc, err := time.LoadLocation("Asia/Tokyo")
+  if nil != err {
+    c.Fatal(err)
+  }
+
+  resolver := func(hostname string) *time.Location {
+    if hostname == "tokyo" {
+      return loc
+    }
+    return nil
+  }
+
+  fixtures := map[string]time.Time{
+    "Oct 11 16:14:15 tokyo ":     time.Date(2015, time.October, 11, 7, 14, 15, 0, time.UTC),
+    "Oct 11 16:14:15 mymachine ": time.Date(2015, time.October, 11, 16, 14, 15, 0, time.UTC),
+  }
+
+  for f, ts := range fixtures {
+    buff := []byte(f)
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+    p.LocationResolver = resolver
+
+    obtained, err := p.parseHeader()
+    c.Assert(err, IsNil)
+    c.Assert(obtained.timestamp, Equals, ts, Commentf(f))
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParse_ReferenceTime(c *C) {
+  buff := []byte("<34>Dec 31 22:14:15 mymachine su: replayed")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+  p.ReferenceTime = time.Date(2011, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+  c.Assert(p.Parse(), IsNil)
+
+  msg := p.Message().(IMessage)
+  c.Assert(msg.TimeStamp(), Equals, time.Date(2010, time.December, 31, 22, 14, 15, 0, time.UTC))
+  c.Assert(msg.YearInferred(), Equals, true)
+}
+
+func (s *Rfc3164TestSuite) TestParse_YearNotInferred(c *C) {
+  buff := []byte("<34>Dec 31 22:14:15 2012 mymachine su: with a year")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+
+  c.Assert(p.Parse(), IsNil)
+
+  msg := p.Message().(IMessage)
+  c.Assert(msg.TimeStamp(), Equals, time.Date(2012, time.December, 31, 22, 14, 15, 0, time.UTC))
+  c.Assert(msg.YearInferred(), Equals, false)
+}
+
 func (s *Rfc3164TestSuite) TestParseHeader_InvalidTimestamp(c *C) {
   buff := []byte("Oct 34 32:72:82 mymachine ")
   hdr := header{}
 
-  s.assertRfc3164Header(c, hdr, buff, lastTriedTimestampLen+1, syslogparser.ErrTimestampUnknownFormat)
+  s.assertRfc3164Header(c, hdr, buff, 0, syslogparser.ErrTimestampUnknownFormat)
 }
 
 func (s *Rfc3164TestSuite) TestParsemessage_Valid(c *C) {
@@ -283,7 +496,208 @@ func (s *Rfc3164TestSuite) TestParseTimestamp_Invalid(c *C) {
   buff := []byte("Oct 34 32:72:82")
   ts := new(time.Time)
 
-  s.assertTimestamp(c, *ts, buff, lastTriedTimestampLen, syslogparser.ErrTimestampUnknownFormat)
+  s.assertTimestamp(c, *ts, buff, 0, syslogparser.ErrTimestampUnknownFormat)
+}
+
+func (s *Rfc3164TestSuite) TestParseTimestamp_Dialects(c *C) {
+  paris, err := time.LoadLocation("Europe/Paris")
+  if nil != err {
+    c.Fatal(err)
+  }
+  time.Local = paris
+
+  fixtures := []struct {
+    buff string
+    ts   time.Time
+  }{
+    // One digit day without padding
+    {"Oct 1 22:14:15 host", time.Date(2015, time.October, 1, 20, 14, 15, 0, time.UTC)},
+    // rsyslog high precision
+    {"2024-03-01T12:00:00.123456+01:00 host", time.Date(2024, time.March, 1, 11, 0, 0, 123456000, time.UTC)},
+    // ESXi
+    {"2024-03-01T12:00:00.123Z host", time.Date(2024, time.March, 1, 12, 0, 0, 123000000, time.UTC)},
+    // Milliseconds
+    {"Oct  1 12:00:00.123 host", time.Date(2015, time.October, 1, 10, 0, 0, 123000000, time.UTC)},
+    // Cisco IOS
+    {"*Oct  1 12:00:00.123 UTC: %SYS-5-CONFIG_I", time.Date(2015, time.October, 1, 12, 0, 0, 123000000, time.UTC)},
+    {"Oct  1 12:00:00.123 UTC: %SYS-5-CONFIG_I", time.Date(2015, time.October, 1, 12, 0, 0, 123000000, time.UTC)},
+    // Juniper
+    {"Mar 1 2024 12:00:00 host", time.Date(2024, time.March, 1, 11, 0, 0, 0, time.UTC)},
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+
+    ts, err := p.parseTimestamp()
+    c.Assert(err, IsNil, Commentf(f.buff))
+    c.Assert(ts, Equals, f.ts, Commentf(f.buff))
+    c.Assert(p.cursor, Equals, strings.LastIndex(f.buff, " ")+1, Commentf(f.buff))
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParseTimestamp_Layouts(c *C) {
+  buff := []byte("01/03/2024 12:00:00 host")
+  p := NewParser(&buff)
+  p.Layouts = []string{"02/01/2006 15:04:05"}
+
+  ts, err := p.parseTimestamp()
+  c.Assert(err, IsNil)
+  c.Assert(ts, Equals, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+  c.Assert(p.cursor, Equals, 20)
+
+  // The default layouts are no longer tried
+  buff = []byte("Oct 11 22:14:15 host")
+  p.Reset(&buff)
+  _, err = p.parseTimestamp()
+  c.Assert(err, Equals, syslogparser.ErrTimestampUnknownFormat)
+}
+
+func (s *Rfc3164TestSuite) TestParse_ZonedTimestampIgnoresResolver(c *C) {
+  buff := []byte("<34>2024-03-01T12:00:00Z tokyo su: zoned")
+  p := NewParser(&buff)
+  p.LocationResolver = func(hostname string) *time.Location { return time.FixedZone("JST", 9*3600) }
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().TimeStamp(), Equals, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+}
+
+func (s *Rfc3164TestSuite) TestParse_TimestampZoneAbbreviation(c *C) {
+  cet := time.FixedZone("CET", 3600)
+  expected := time.Date(2024, time.March, 1, 11, 0, 0, 123000000, time.UTC)
+
+  newParser := func(buff []byte) *Parser {
+    p := NewParser(&buff)
+    p.ReferenceTime = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+    p.Location = time.UTC
+    return p
+  }
+
+  // Known from Zones
+  p := newParser([]byte("<189>Mar  1 12:00:00.123 CET: router su: msg"))
+  p.Zones = map[string]*time.Location{"CET": cet}
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().TimeStamp(), Equals, expected)
+  c.Assert(p.Message().(IMessage).TimestampInfo().Offset, Equals, 3600)
+
+  // Unknown, resolved as the unzoned timestamps of the host
+  p = newParser([]byte("<189>Mar  1 12:00:00.123 CET: router su: msg"))
+  p.LocationResolver = func(hostname string) *time.Location { return cet }
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().TimeStamp(), Equals, expected)
+
+  p = newParser([]byte("<189>Mar  1 12:00:00.123 CET: router su: msg"))
+  p.Location = cet
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().TimeStamp(), Equals, expected)
+}
+
+func (s *Rfc3164TestSuite) TestParseTimestamp_UnknownZoneNotShared(c *C) {
+  buff := []byte("Mar  1 12:00:00 XYZ: router")
+  p := NewParser(&buff)
+  p.Location = time.UTC
+
+  ts, err := p.parseTimestamp()
+  c.Assert(err, IsNil)
+  copy(buff[16:19], "ABC")
+
+  zone, offset := ts.Zone()
+  c.Assert(zone, Equals, "UTC")
+  c.Assert(offset, Equals, 0)
+}
+
+func (s *Rfc3164TestSuite) TestParse_TimestampInfo(c *C) {
+  fixtures := []struct {
+    buff string
+    info message.TimestampInfo
+  }{
+    {"<34>Oct 11 22:14:15 host su: msg", message.TimestampInfo{Raw: "Oct 11 22:14:15", Offset: 9 * 3600}},
+    {"<34>2024-03-01T12:00:00.123456+01:00 host su: msg", message.TimestampInfo{Raw: "2024-03-01T12:00:00.123456+01:00", Precision: 6, Offset: 3600}},
+    {"<34>*Oct  1 12:00:00.123 UTC: router su: msg", message.TimestampInfo{Raw: "*Oct  1 12:00:00.123 UTC:", Precision: 3}},
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+    p.Location = time.FixedZone("JST", 9*3600)
+
+    c.Assert(p.Parse(), IsNil, Commentf(f.buff))
+    c.Assert(p.Message().(IMessage).TimestampInfo(), Equals, f.info, Commentf(f.buff))
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParse_Decoder(c *C) {
+  latin1, err := charset.Decoder(charset.LATIN1)
+  c.Assert(err, IsNil)
+
+  buff := []byte("<34>Oct 11 22:14:15 h\xF4te su: cr\xE8me br\xFBl\xE9e")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+  p.Decoder = latin1
+
+  c.Assert(p.Parse(), IsNil)
+  msg := p.Message().(IMessage)
+  c.Assert(msg.Hostname(), Equals, "hôte")
+  c.Assert(msg.Tag(), Equals, "su")
+  c.Assert(msg.Content(), Equals, "crème brûlée")
+  c.Assert(msg.DetectedCharset(), Equals, "")
+}
+
+func (s *Rfc3164TestSuite) TestParse_DecoderResolver(c *C) {
+  sjis, err := charset.Decoder(charset.SHIFT_JIS)
+  c.Assert(err, IsNil)
+
+  resolver := func(hostname string) syslogparser.CharsetDecoder {
+    if hostname == "tokyo" {
+      return sjis
+    }
+    return nil
+  }
+
+  fixtures := []struct {
+    buff    string
+    content string
+  }{
+    {"<34>Oct 11 22:14:15 tokyo su: \x83\x65\x83\x58\x83\x67", "テスト"},
+    {"<34>Oct 11 22:14:15 paris su: caf\xE9", "caf\xE9"},
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+    p.DecoderResolver = resolver
+
+    c.Assert(p.Parse(), IsNil)
+    c.Assert(p.Message().(IMessage).Content(), Equals, f.content)
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParse_DetectCharset(c *C) {
+  fixtures := []struct {
+    buff    string
+    content string
+    charset string
+  }{
+    {"<34>Oct 11 22:14:15 host su: ok", "ok", charset.ASCII},
+    {"<34>Oct 11 22:14:15 host su: caf\xC3\xA9", "café", charset.UTF8},
+    {"<34>Oct 11 22:14:15 host su: \x93caf\xE9\x94", "“café”", charset.WINDOWS_1252},
+    {"<34>Oct 11 22:14:15 host su: \x83\x65\x83\x58\x83\x67", "テスト", charset.SHIFT_JIS},
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+    p.DetectCharset = true
+
+    c.Assert(p.Parse(), IsNil)
+    msg := p.Message().(IMessage)
+    c.Assert(msg.Content(), Equals, f.content)
+    c.Assert(msg.DetectedCharset(), Equals, f.charset)
+  }
 }
 
 func (s *Rfc3164TestSuite) TestParseTimestamp_TrailingSpace(c *C) {
@@ -418,6 +832,41 @@ func (s *Rfc3164TestSuite) TestParseContent_NoPidOrMsgButSep(c *C) {
   c.Assert(pid, Equals, "")
 }
 
+func (s *Rfc3164TestSuite) TestParseContent_TruncatedPid(c *C) {
+  fixtures := []struct {
+    buff    string
+    pid     string
+    content string
+  }{
+    {"[", "", "["},
+    {"[123", "", "[123"},
+    {"[123]", "123", ""},
+    {"[123]:", "123", ""},
+  }
+
+  for _, f := range fixtures {
+    // Without spare capacity, reading past the end panics
+    buff := []byte(f.buff)
+    buff = buff[:len(buff):len(buff)]
+    p := NewParser(&buff)
+    pid, obtained, err := p.parseContent()
+    c.Assert(err, Equals, syslogparser.ErrEOL)
+    c.Assert(pid, Equals, f.pid, Commentf("%q", f.buff))
+    c.Assert(obtained, Equals, f.content, Commentf("%q", f.buff))
+  }
+}
+
+func (s *Rfc3164TestSuite) TestParser_TruncatedPid(c *C) {
+  buff := []byte("<34>Oct 11 22:14:15 host su[")
+  buff = buff[:len(buff):len(buff)]
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Dump()["tag"], Equals, "su")
+  c.Assert(p.Dump()["content"], Equals, "[")
+}
+
 func (s *Rfc3164TestSuite) BenchmarkParseTimestamp(c *C) {
   buff := []byte("Oct 11 22:14:15")
 
@@ -533,3 +982,72 @@ func (s *Rfc3164TestSuite) assertRfc3164message(c *C, msg rfc3164message, b []by
   c.Assert(obtained, Equals, msg)
   c.Assert(p.cursor, Equals, expC)
 }
+
+func (s *Rfc3164TestSuite) TestParseInto_Reuse(c *C) {
+  first := []byte("<34>Oct 11 22:14:15 mymachine su: 'su root' failed")
+  second := []byte("<94>Oct 10 20:07:15 webtest-mark simlogging[17155]: This is a log.info() message")
+  bad := []byte("FOO BAR BAZ")
+
+  p := NewParser(&first)
+  p.TimeFunction = octTestDate
+  var msg Rfc3164Message
+
+  c.Assert(p.ParseInto(&first, &msg), IsNil)
+  c.Assert(msg.Hostname(), Equals, "mymachine")
+  c.Assert(msg.Tag(), Equals, "su")
+  c.Assert(msg.Content(), Equals, "'su root' failed")
+
+  c.Assert(p.ParseInto(&second, &msg), IsNil)
+  c.Assert(msg.Hostname(), Equals, "webtest-mark")
+  c.Assert(msg.Pid(), Equals, "17155")
+  c.Assert(msg.TimeStamp(), Equals, time.Date(2015, time.October, 10, 20, 7, 15, 0, time.UTC))
+  c.Assert(string(*msg.RawMessage()), Equals, string(second))
+
+  c.Assert(p.ParseInto(&bad, &msg), NotNil)
+  c.Assert(msg.Hostname(), Equals, "")
+  c.Assert(string(*msg.RawMessage()), Equals, string(bad))
+
+  p.Reset(&first)
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().Hostname(), Equals, "mymachine")
+}
+
+func (s *Rfc3164TestSuite) TestParseInto_ZeroAllocations(c *C) {
+  buff := []byte("<94>Oct 10 20:07:15 webtest-mark simlogging[17155]: This is a log.info() message")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+  var msg Rfc3164Message
+
+  allocs := testing.AllocsPerRun(100, func() {
+    if err := p.ParseInto(&buff, &msg); err != nil {
+      panic(err)
+    }
+  })
+  c.Assert(allocs, Equals, 0.0)
+}
+
+func (s *Rfc3164TestSuite) BenchmarkParseInto(c *C) {
+  buff := []byte("<94>Oct 10 20:07:15 webtest-mark simlogging[17155]: This is a log.info() message")
+  p := NewParser(&buff)
+  p.TimeFunction = octTestDate
+  var msg Rfc3164Message
+
+  for i := 0; i < c.N; i++ {
+    if err := p.ParseInto(&buff, &msg); err != nil {
+      panic(err)
+    }
+  }
+}
+
+func (s *Rfc3164TestSuite) BenchmarkNewParser(c *C) {
+  buff := []byte("<94>Oct 10 20:07:15 webtest-mark simlogging[17155]: This is a log.info() message")
+
+  for i := 0; i < c.N; i++ {
+    p := NewParser(&buff)
+    p.TimeFunction = octTestDate
+    if err := p.Parse(); err != nil {
+      panic(err)
+    }
+    p.Message()
+  }
+}
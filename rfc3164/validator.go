@@ -0,0 +1,165 @@
+package rfc3164
+
+import (
+  "fmt"
+  "github.com/scalingdata/syslogparser"
+  "net"
+  "time"
+)
+
+const (
+  // https://tools.ietf.org/html/rfc3164#section-4.1
+  MAX_PACKET_LEN = 1024
+  // https://tools.ietf.org/html/rfc3164#section-4.1.3
+  MAX_TAG_LEN = 32
+)
+
+type validator struct {
+  buff       []byte
+  cursor     int
+  l          int
+  violations []syslogparser.Violation
+}
+
+/* Validate checks buff against the rules of RFC 3164 and reports every
+   violation found. It goes on after a violation as long as the next fields
+   can still be found. */
+func Validate(buff []byte) []syslogparser.Violation {
+  v := &validator{
+    buff: buff,
+    l:    len(buff),
+  }
+
+  v.validate()
+  return v.violations
+}
+
+func (v *validator) validate() {
+  if v.l > MAX_PACKET_LEN {
+    v.report(syslogparser.FIELD_MSG, MAX_PACKET_LEN, "Message longer than %d bytes", MAX_PACKET_LEN)
+  }
+
+  violations, err := syslogparser.ValidatePriority(FORMAT, v.buff, &v.cursor, v.l)
+  v.violations = append(v.violations, violations...)
+
+  // Relays add a PRI to messages without one, the rest can still be checked
+  if err != nil && err != syslogparser.ErrPriorityNoStart {
+    return
+  }
+
+  if !v.validateTimestamp() || !v.validateHostname() {
+    return
+  }
+
+  v.validateTag()
+  v.validateContent()
+}
+
+func (v *validator) report(field string, offset int, format string, args ...interface{}) {
+  v.violations = append(v.violations, syslogparser.Violation{
+    Format: FORMAT,
+    Field:  field,
+    Offset: offset,
+    Reason: fmt.Sprintf(format, args...),
+  })
+}
+
+// TIMESTAMP = Mmm dd hh:mm:ss, days below 10 being padded with a space
+func (v *validator) validateTimestamp() bool {
+  from := v.cursor
+  end := from + len(time.Stamp)
+
+  if end > v.l {
+    v.report(syslogparser.FIELD_TIMESTAMP, from, "%s", syslogparser.ErrTimestampUnknownFormat)
+    return false
+  }
+
+  _, err := time.Parse(time.Stamp, string(v.buff[from:end]))
+  if err != nil {
+    v.report(syslogparser.FIELD_TIMESTAMP, from, "%s", syslogparser.ErrTimestampUnknownFormat)
+    return false
+  }
+
+  if v.buff[from+4] == '0' {
+    v.report(syslogparser.FIELD_TIMESTAMP, from+4, "Day of month padded with a zero instead of a space")
+  }
+
+  v.cursor = end
+  if v.cursor >= v.l || v.buff[v.cursor] != ' ' {
+    v.report(syslogparser.FIELD_TIMESTAMP, v.cursor, "%s", syslogparser.ErrNoSpace)
+    return false
+  }
+
+  v.cursor++
+  return true
+}
+
+// The HOSTNAME is a host name without domain, or an IP address
+func (v *validator) validateHostname() bool {
+  from := v.cursor
+  hostname, _ := syslogparser.ParseHostnameBytes(v.buff, &v.cursor, v.l)
+
+  if len(hostname) == 0 {
+    v.report(syslogparser.FIELD_HOSTNAME, from, "Empty %s", syslogparser.FIELD_HOSTNAME)
+  } else if net.ParseIP(string(hostname)) == nil {
+    for i, c := range hostname {
+      if c < 33 || c > 126 {
+        v.report(syslogparser.FIELD_HOSTNAME, from+i, "Non PRINTUSASCII character 0x%02x", c)
+        break
+      }
+
+      if c == '.' {
+        v.report(syslogparser.FIELD_HOSTNAME, from+i, "Domain name in %s", syslogparser.FIELD_HOSTNAME)
+        break
+      }
+    }
+  }
+
+  if v.cursor >= v.l {
+    v.report(syslogparser.FIELD_TAG, v.cursor, "Missing %s", syslogparser.FIELD_TAG)
+    return false
+  }
+
+  v.cursor++
+  return true
+}
+
+// The TAG is at most 32 alphanumeric characters
+func (v *validator) validateTag() {
+  from := v.cursor
+  p := &Parser{
+    buff:   v.buff,
+    cursor: from,
+    l:      v.l,
+  }
+
+  tag, _ := p.parseTag()
+  v.cursor = p.cursor
+
+  if len(tag) == 0 {
+    v.report(syslogparser.FIELD_TAG, from, "Missing %s", syslogparser.FIELD_TAG)
+    return
+  }
+
+  if len(tag) > MAX_TAG_LEN {
+    v.report(syslogparser.FIELD_TAG, from, "%s longer than %d characters", syslogparser.FIELD_TAG, MAX_TAG_LEN)
+  }
+
+  for i := 0; i < len(tag); i++ {
+    c := tag[i]
+    if !syslogparser.IsDigit(c) && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') {
+      v.report(syslogparser.FIELD_TAG, from+i, "Non alphanumeric character '%c' in %s", c, syslogparser.FIELD_TAG)
+      return
+    }
+  }
+}
+
+func (v *validator) validateContent() {
+  for i := v.cursor; i < v.l; i++ {
+    c := v.buff[i]
+    if c < 32 || c > 126 {
+      v.report(syslogparser.FIELD_MSG, i, "Non printable character 0x%02x", c)
+      return
+    }
+  }
+}
@@ -0,0 +1,71 @@
+package rfc3164
+
+import (
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  "strings"
+)
+
+type ValidatorTestSuite struct {
+}
+
+var _ = Suite(&ValidatorTestSuite{})
+
+func (s *ValidatorTestSuite) TestValidate_Valid(c *C) {
+  fixtures := []string{
+    "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+    "<13>Feb  5 17:32:18 10.0.0.99 Use the BFG!",
+    "<0>Oct 11 22:14:15 mymachine sshd[123]: Accepted publickey",
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Validate([]byte(f)), HasLen, 0, Commentf("%q", f))
+  }
+}
+
+func (s *ValidatorTestSuite) TestValidate_Violations(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected []syslogparser.Violation
+  }{
+    {
+      "<200>Oct 01 22:14:15 mymachine.example.com su: failed",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_PRI, 1, "Priority greater than 191"},
+        {FORMAT, syslogparser.FIELD_TIMESTAMP, 9, "Day of month padded with a zero instead of a space"},
+        {FORMAT, syslogparser.FIELD_HOSTNAME, 30, "Domain name in HOSTNAME"},
+      },
+    },
+    {
+      "Oct 11 22:14:15 mymachine " + strings.Repeat("t", 33) + "_x: caf\xC3\xA9",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_PRI, 0, "No start char found for priority"},
+        {FORMAT, syslogparser.FIELD_TAG, 26, "TAG longer than 32 characters"},
+        {FORMAT, syslogparser.FIELD_TAG, 59, "Non alphanumeric character '_' in TAG"},
+        {FORMAT, syslogparser.FIELD_MSG, 66, "Non printable character 0xc3"},
+      },
+    },
+    {
+      "<34>2003-10-11T22:14:15.003Z mymachine su: failed",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_TIMESTAMP, 4, "Timestamp format unknown"},
+      },
+    },
+    {
+      "<34>Oct 11 22:14:15 mymachine",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_TAG, 29, "Missing TAG"},
+      },
+    },
+    {
+      "<34>Oct 11 22:14:15 mymachine su: " + strings.Repeat("x", MAX_PACKET_LEN),
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_MSG, MAX_PACKET_LEN, "Message longer than 1024 bytes"},
+      },
+    },
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Validate([]byte(f.buff)), DeepEquals, f.expected, Commentf("%q", f.buff))
+  }
+}
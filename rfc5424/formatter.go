@@ -0,0 +1,175 @@
+package rfc5424
+
+import (
+  "bytes"
+  "io"
+  "strconv"
+  "strings"
+  "github.com/scalingdata/syslogparser"
+  message "github.com/scalingdata/syslogparser/message"
+  "time"
+)
+
+const (
+  // TIME-SECFRAC = "." 1*6DIGIT
+  MAX_PRECISION = 6
+)
+
+/* Formatter turns a message.IMessage into an RFC 5424 line.
+   Messages implementing rfc5424.IMessage keep their VERSION, MSGID and
+   STRUCTURED-DATA, other messages get NILVALUEs for the fields they lack. */
+type Formatter struct {
+  // Number of TIME-SECFRAC digits, from 0 to MAX_PRECISION
+  Precision int
+}
+
+func NewFormatter() *Formatter {
+  return &Formatter{
+    Precision: MAX_PRECISION,
+  }
+}
+
+func (f *Formatter) Write(w io.Writer, msg message.IMessage) error {
+  _, err := w.Write(f.Format(msg))
+  return err
+}
+
+// SYSLOG-MSG = HEADER SP STRUCTURED-DATA [SP MSG]
+func (f *Formatter) Format(msg message.IMessage) []byte {
+  var buff bytes.Buffer
+
+  version := 1
+  appName := msg.Process()
+  procId := msg.Pid()
+  msgId := ""
+  sd := ""
+  timestamp := f.formatTimestamp(msg.TimeStamp())
+  declaredUTF8 := false
+
+  if rfcMsg, ok := msg.(IMessage); ok {
+    if rfcMsg.Version() > 0 {
+      version = rfcMsg.Version()
+    }
+    appName = rfcMsg.AppName()
+    procId = rfcMsg.ProcId()
+    msgId = rfcMsg.MsgId()
+    declaredUTF8 = rfcMsg.DeclaredUTF8()
+
+    if rfcMsg.TimestampInfo().Absent {
+      timestamp = string(NILVALUE)
+    }
+
+    if len(rfcMsg.SDElements()) > 0 {
+      sd = FormatStructuredData(rfcMsg.SDElements())
+    } else {
+      sd = rfcMsg.StructuredData()
+    }
+  }
+
+  pri, _ := syslogparser.ComputePriority(msg.Facility(), msg.Severity())
+
+  buff.WriteByte(syslogparser.PRI_PART_START)
+  buff.WriteString(strconv.Itoa(pri.P))
+  buff.WriteByte(syslogparser.PRI_PART_END)
+  buff.WriteString(strconv.Itoa(version))
+  buff.WriteByte(' ')
+  buff.WriteString(timestamp)
+  buff.WriteByte(' ')
+  buff.WriteString(formatField(msg.Hostname(), 255))
+  buff.WriteByte(' ')
+  buff.WriteString(formatField(appName, 48))
+  buff.WriteByte(' ')
+  buff.WriteString(formatField(procId, 128))
+  buff.WriteByte(' ')
+  buff.WriteString(formatField(msgId, 32))
+  buff.WriteByte(' ')
+
+  if sd == "" {
+    buff.WriteByte(NILVALUE)
+  } else {
+    buff.WriteString(sd)
+  }
+
+  if content := msg.Message(); content != "" || declaredUTF8 {
+    buff.WriteByte(' ')
+    if declaredUTF8 {
+      buff.Write(bom)
+    }
+    buff.WriteString(content)
+  }
+
+  return buff.Bytes()
+}
+
+// TIMESTAMP = NILVALUE / FULL-DATE "T" FULL-TIME
+func (f *Formatter) formatTimestamp(ts time.Time) string {
+  if ts.IsZero() {
+    return string(NILVALUE)
+  }
+
+  precision := f.Precision
+  if precision < 0 {
+    precision = 0
+  } else if precision > MAX_PRECISION {
+    precision = MAX_PRECISION
+  }
+
+  layout := "2006-01-02T15:04:05"
+  if precision > 0 {
+    layout += "." + strings.Repeat("0", precision)
+  }
+
+  return ts.Format(layout + "Z07:00")
+}
+
+// FormatStructuredData renders SD-ELEMENTs, escaping '"', '\' and ']' in
+// PARAM-VALUEs as required by https://tools.ietf.org/html/rfc5424#section-6.3.3
+func FormatStructuredData(elements []SDElement) string {
+  var buff bytes.Buffer
+
+  if len(elements) == 0 {
+    return string(NILVALUE)
+  }
+
+  for _, elem := range elements {
+    buff.WriteByte('[')
+    buff.WriteString(elem.ID)
+    for _, param := range elem.Params {
+      buff.WriteByte(' ')
+      buff.WriteString(param.Name)
+      buff.WriteString(`="`)
+      for i := 0; i < len(param.Value); i++ {
+        c := param.Value[i]
+        if c == '"' || c == '\\' || c == ']' {
+          buff.WriteByte('\\')
+        }
+        buff.WriteByte(c)
+      }
+      buff.WriteByte('"')
+    }
+    buff.WriteByte(']')
+  }
+
+  return buff.String()
+}
+
+/* Header fields are PRINTUSASCII without spaces, replace anything else and
+   truncate to the field maximum length. Empty fields become NILVALUE. */
+func formatField(field string, maxLen int) string {
+  if field == "" {
+    return string(NILVALUE)
+  }
+
+  b := []byte(field)
+  if len(b) > maxLen {
+    b = b[:maxLen]
+  }
+
+  for i, c := range b {
+    if c < 33 || c > 126 {
+      b[i] = '_'
+    }
+  }
+
+  return string(b)
+}
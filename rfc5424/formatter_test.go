@@ -0,0 +1,93 @@
+package rfc5424
+
+import (
+  . "github.com/scalingdata/check"
+  message "github.com/scalingdata/syslogparser/message"
+  "time"
+)
+
+type Rfc5424FormatterTestSuite struct {
+}
+
+var _ = Suite(&Rfc5424FormatterTestSuite{})
+
+func (s *Rfc5424FormatterTestSuite) TestFormat_RoundTrip(c *C) {
+  fixtures := []string{
+    "<34>1 2003-10-11T22:14:15.003000Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8",
+    "<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts.",
+    `<165>1 2003-10-11T22:14:15.003000Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry...`,
+    `<165>1 2003-10-11T22:14:15.003000Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"][examplePriority@32473 class="high"]`,
+    `<165>1 - - - - - [id escaped="a\"b\\c\]d"] msg`,
+    "<165>1 - - - - - - \xEF\xBB\xBFcaf\xC3\xA9",
+  }
+
+  f := NewFormatter()
+  for _, fixture := range fixtures {
+    buff := []byte(fixture)
+    p := NewParser(&buff)
+    err := p.Parse()
+    c.Assert(err, IsNil)
+
+    c.Assert(string(f.Format(p.Message())), Equals, fixture)
+  }
+}
+
+func (s *Rfc5424FormatterTestSuite) TestFormat_Precision(c *C) {
+  buff := []byte("<34>1 2003-10-11T22:14:15.123456+02:00 host app 1 ID47 - msg")
+  p := NewParser(&buff)
+  c.Assert(p.Parse(), IsNil)
+
+  f := NewFormatter()
+  f.Precision = 3
+  c.Assert(string(f.Format(p.Message())), Equals, "<34>1 2003-10-11T22:14:15.123+02:00 host app 1 ID47 - msg")
+
+  f.Precision = 0
+  c.Assert(string(f.Format(p.Message())), Equals, "<34>1 2003-10-11T22:14:15+02:00 host app 1 ID47 - msg")
+}
+
+func (s *Rfc5424FormatterTestSuite) TestFormat_UnparsableMessage(c *C) {
+  buff := []byte("FOO BAR BAZ")
+  msg := message.NewUnparsableMessage(&buff)
+
+  f := NewFormatter()
+  obtained := string(f.Format(msg))
+  expected := "<13>1 " + msg.TimeStamp().Format("2006-01-02T15:04:05.000000Z07:00") + " - - - - -"
+  c.Assert(obtained, Equals, expected)
+}
+
+func (s *Rfc5424FormatterTestSuite) TestFormatStructuredData(c *C) {
+  elements := []SDElement{
+    SDElement{
+      ID: "exampleSDID@32473",
+      Params: []SDParam{
+        SDParam{Name: "iut", Value: "3"},
+        SDParam{Name: "path", Value: `C:\logs\[app].log`},
+      },
+    },
+    SDElement{ID: "origin"},
+  }
+
+  c.Assert(FormatStructuredData(elements), Equals, `[exampleSDID@32473 iut="3" path="C:\\logs\\[app\].log"][origin]`)
+  c.Assert(FormatStructuredData(nil), Equals, "-")
+}
+
+func (s *Rfc5424FormatterTestSuite) TestFormatField(c *C) {
+  c.Assert(formatField("", 48), Equals, "-")
+  c.Assert(formatField("my app", 48), Equals, "my_app")
+  c.Assert(formatField("aaaaaaaaaa", 4), Equals, "aaaa")
+}
+
+func (s *Rfc5424FormatterTestSuite) TestFormat_AbsentTimestamp(c *C) {
+  buff := []byte("<34>1 - host app 1 ID47 - msg")
+  p := NewParser(&buff)
+  p.ReceiveTimeWhenAbsent = true
+  c.Assert(p.Parse(), IsNil)
+
+  f := NewFormatter()
+  c.Assert(string(f.Format(p.Message())), Equals, string(buff))
+}
+
+func (s *Rfc5424FormatterTestSuite) TestFormatTimestamp_NilValue(c *C) {
+  f := NewFormatter()
+  c.Assert(f.formatTimestamp(time.Time{}), Equals, "-")
+}
@@ -5,6 +5,21 @@ import (
   "time"
 )
 
+/* IMessage exposes the RFC 5424 specific fields on top of the common
+   message.IMessage, type assert a message.IMessage to get to them. */
+type IMessage interface {
+  message.IMessage
+  Version() int
+  AppName() string
+  ProcId() string
+  MsgId() string
+  StructuredData() string
+  SDElements() []SDElement
+  TimestampInfo() message.TimestampInfo
+  // The MSG started with a BOM, declaring it UTF-8
+  DeclaredUTF8() bool
+}
+
 type Rfc5424Message struct {
   rawMsg *[]byte
   ts time.Time
@@ -17,6 +32,9 @@ type Rfc5424Message struct {
   version int
   msgId string
   structuredData string
+  sdElements []SDElement
+  timestampInfo message.TimestampInfo
+  declaredUTF8 bool
 }
 
 func (self Rfc5424Message) RawMessage() *[]byte {
@@ -51,3 +69,35 @@ func (self Rfc5424Message) Process() string {
   return self.appName
 }
 
+
+func (self Rfc5424Message) Version() int {
+  return self.version
+}
+
+func (self Rfc5424Message) AppName() string {
+  return self.appName
+}
+
+func (self Rfc5424Message) ProcId() string {
+  return self.pid
+}
+
+func (self Rfc5424Message) MsgId() string {
+  return self.msgId
+}
+
+func (self Rfc5424Message) StructuredData() string {
+  return self.structuredData
+}
+
+func (self Rfc5424Message) SDElements() []SDElement {
+  return self.sdElements
+}
+
+func (self Rfc5424Message) TimestampInfo() message.TimestampInfo {
+  return self.timestampInfo
+}
+
+func (self Rfc5424Message) DeclaredUTF8() bool {
+  return self.declaredUTF8
+}
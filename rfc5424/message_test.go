@@ -34,6 +34,25 @@ func (s *Rfc5424MessageTestSuite) TestMessageFromRfc5424(c *C) {
   c.Assert("23456", Equals, msg.Pid())
 }
 
+func (s *Rfc5424MessageTestSuite) TestMessageRfc5424Fields(c *C) {
+  parser := NewParser(&sampleRfc5424Log)
+  err := parser.Parse()
+  if nil != err {
+    c.Fatal(err)
+  }
+  var msg message.IMessage = parser.Message()
+  rfcMsg, ok := msg.(IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert(1, Equals, rfcMsg.Version())
+  c.Assert("simlogging", Equals, rfcMsg.AppName())
+  c.Assert("23456", Equals, rfcMsg.ProcId())
+  c.Assert("ID47", Equals, rfcMsg.MsgId())
+  c.Assert(`[exampleSDID@32473 iut="9" eventSource="rawr" eventID="123"]`, Equals, rfcMsg.StructuredData())
+  c.Assert(1, Equals, len(rfcMsg.SDElements()))
+  c.Assert("exampleSDID@32473", Equals, rfcMsg.SDElements()[0].ID)
+  c.Assert(3, Equals, len(rfcMsg.SDElements()[0].Params))
+}
+
 func (s *Rfc5424MessageTestSuite) TestMessageCantParseMessage(c *C) {
   badMsg := []byte("FOO BAR BAZ")
   parser := NewParser(&badMsg)
@@ -50,4 +69,6 @@ func (s *Rfc5424MessageTestSuite) TestMessageCantParseMessage(c *C) {
   c.Assert(message.SeverityUnknown, Equals, msg.Severity())
   c.Assert(message.FacilityUnknown, Equals, msg.Facility())
   c.Assert("", Equals, msg.Pid())
+  _, ok := msg.(IMessage)
+  c.Assert(ok, Equals, false)
 }
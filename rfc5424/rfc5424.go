@@ -4,16 +4,32 @@
 package rfc5424
 
 import (
-  "fmt"
+  "bytes"
   "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/internal/zerocopy"
   message "github.com/scalingdata/syslogparser/message"
-  "math"
   "strconv"
+  "strings"
+  "sync"
   "time"
+  "unicode/utf8"
 )
 
 const (
   NILVALUE = '-'
+
+  // Reported in syslogparser.PositionError
+  FORMAT = "RFC5424"
+
+  // https://tools.ietf.org/html/rfc5424#section-6
+  MAX_HOSTNAME_LEN = 255
+  MAX_APP_NAME_LEN = 48
+  MAX_PROCID_LEN   = 128
+  MAX_MSGID_LEN    = 32
+  MAX_SD_NAME_LEN  = 32
+
+  // TIME-SECFRAC digits read, beyond MAX_PRECISION as some senders go to nanoseconds
+  MAX_SECFRAC_DIGITS = 9
 )
 
 var (
@@ -30,6 +46,16 @@ var (
   ErrInvalidProcId     = &syslogparser.ParserError{"Invalid proc ID"}
   ErrInvalidMsgId      = &syslogparser.ParserError{"Invalid msg ID"}
   ErrNoStructuredData  = &syslogparser.ParserError{"No structured data"}
+  ErrInvalidSDID       = &syslogparser.ParserError{"Invalid SD-ID in structured data"}
+  ErrInvalidSDParam    = &syslogparser.ParserError{"Invalid SD-PARAM in structured data"}
+  ErrSDParamNoValue    = &syslogparser.ParserError{"SD-PARAM value is not quoted"}
+  ErrSDParamNoEnd      = &syslogparser.ParserError{"Unterminated SD-PARAM value"}
+  ErrSDElementNoEnd    = &syslogparser.ParserError{"Unterminated SD-ELEMENT"}
+  ErrSDNoSpace         = &syslogparser.ParserError{"No space after structured data"}
+  ErrInvalidUTF8       = &syslogparser.ParserError{"MSG starts with a BOM but is not valid UTF-8"}
+
+  // https://tools.ietf.org/html/rfc5424#section-6.4
+  bom = []byte{0xEF, 0xBB, 0xBF}
 )
 
 type Parser struct {
@@ -38,8 +64,72 @@ type Parser struct {
   l              int
   header         header
   structuredData string
+  sdElements     []SDElement
   message        string
   parseSuccessful bool
+  // Field being parsed, reported on errors
+  field          string
+  // Set by parseTimestamp
+  timestampInfo  message.TimestampInfo
+  // Fields share buff instead of copying it, see SetZeroCopy
+  zeroCopy       bool
+  /* ReceiveTimeWhenAbsent gives messages with a NILVALUE timestamp the time
+     of TimeFunction instead of a zero time, TimestampInfo().Absent still
+     tells the timestamp was missing. */
+  ReceiveTimeWhenAbsent bool
+  // Receive time of the messages, time.Now when nil
+  TimeFunction          func() time.Time
+  // What to do with messages of a VERSION other than 1
+  VersionPolicy         VersionPolicy
+  // Parsers of other versions, used with DISPATCH_VERSION
+  VersionHandlers       map[int]syslogparser.ParserFactory
+  // Parser the message was handed over to by its version handler
+  delegate              syslogparser.LogParser
+  // What to do with MSGs starting with a BOM but not valid UTF-8
+  UTF8Policy            UTF8Policy
+  /* Decoder converts MSGs without BOM, octets of a charset the RFC leaves
+     unknown, to UTF-8. They are kept as is when nil. */
+  Decoder               syslogparser.CharsetDecoder
+  // Set by parseMsg when the MSG starts with a BOM
+  declaredUTF8          bool
+}
+
+type UTF8Policy int
+
+const (
+  // MSGs are kept as is, invalid UTF-8 included
+  PASS_INVALID_UTF8 UTF8Policy = iota
+  // Invalid UTF-8 sequences are replaced by U+FFFD
+  REPLACE_INVALID_UTF8
+  // Messages with invalid UTF-8 fail with ErrInvalidUTF8
+  REJECT_INVALID_UTF8
+)
+
+type VersionPolicy int
+
+const (
+  // Messages of any version are parsed as version 1, Version() still
+  // gives the version sent
+  ACCEPT_VERSION_AS_1 VersionPolicy = iota
+  // Messages of other versions fail with syslogparser.ErrVersionUnsupported
+  REJECT_VERSION
+  /* Messages of other versions are parsed by the parser VersionHandlers
+     builds for their version, and fail with
+     syslogparser.ErrVersionUnsupported when there is none. */
+  DISPATCH_VERSION
+)
+
+// SD-ELEMENT = "[" SD-ID *(SP SD-PARAM) "]"
+type SDElement struct {
+  ID     string
+  Params []SDParam
+}
+
+// SD-PARAM = PARAM-NAME "=" %d34 PARAM-VALUE %d34
+// Value holds the unescaped PARAM-VALUE.
+type SDParam struct {
+  Name  string
+  Value string
 }
 
 type header struct {
@@ -50,13 +140,18 @@ type header struct {
   appName   string
   procId    string
   msgId     string
+  timestampInfo message.TimestampInfo
 }
 
 type partialTime struct {
   hour    int
   minute  int
   seconds int
-  secFrac float64
+  nSec    int
+  // Number of TIME-SECFRAC digits
+  precision int
+  // TIME-SECOND was 60
+  leapSecond bool
 }
 
 type fullTime struct {
@@ -79,31 +174,142 @@ func NewParser(buff *[]byte) *Parser {
   }
 }
 
+// Reset prepares the parser for a new message
+func (p *Parser) Reset(buff *[]byte) {
+  p.buff = *buff
+  p.cursor = 0
+  p.l = len(*buff)
+  p.header = header{}
+  p.structuredData = ""
+  p.sdElements = nil
+  p.message = ""
+  p.parseSuccessful = false
+  p.field = ""
+  p.timestampInfo = message.TimestampInfo{}
+  p.delegate = nil
+  p.declaredUTF8 = false
+}
+
+/* SetZeroCopy makes the parsed fields share the memory of the buffer
+   instead of copying it, the buffer must then not be modified while they
+   are in use. */
+func (p *Parser) SetZeroCopy(zeroCopy bool) {
+  p.zeroCopy = zeroCopy
+}
+
+/* ParseInto parses buff into msg without allocating in the common case :
+   the parser and msg can be reused for every message, msg keeps its
+   SD-ELEMENTs storage from one message to the next. The strings of msg
+   share the memory of buff, so buff must not be modified while msg is in
+   use. Messages are not handed over to VersionHandlers, those of versions
+   other than 1 fail under DISPATCH_VERSION. */
+func (p *Parser) ParseInto(buff *[]byte, msg *Rfc5424Message) error {
+  p.Reset(buff)
+  p.sdElements = msg.sdElements[:0]
+  zeroCopy := p.zeroCopy
+  p.zeroCopy = true
+
+  err := p.parse(false)
+  p.zeroCopy = zeroCopy
+  if err != nil {
+    *msg = Rfc5424Message{rawMsg: buff, sdElements: p.sdElements[:0]}
+    return err
+  }
+
+  p.fillMessage(msg, buff)
+  return nil
+}
+
+/* Detect tells how likely buff is an RFC 5424 message from its PRI,
+   VERSION and the shape of its TIMESTAMP, in a single pass over them. */
+func Detect(buff []byte) syslogparser.Confidence {
+  cursor := 0
+  l := len(buff)
+
+  if _, err := syslogparser.ParsePriority(buff, &cursor, l); err != nil {
+    return syslogparser.NO_MATCH
+  }
+
+  _, err := syslogparser.ParseVersion(buff, &cursor, l)
+  if err != nil || cursor >= l || buff[cursor] != ' ' {
+    return syslogparser.UNLIKELY
+  }
+
+  ts := buff[cursor+1:]
+  if len(ts) > 0 && ts[0] == NILVALUE && (len(ts) == 1 || ts[1] == ' ') {
+    return syslogparser.CERTAIN
+  }
+
+  // FULL-DATE "T"
+  if syslogparser.StartsWithFullDate(ts) && len(ts) > 10 && ts[10] == 'T' {
+    return syslogparser.CERTAIN
+  }
+
+  return syslogparser.UNLIKELY
+}
+
 func (p *Parser) Parse() error {
+  return p.parse(true)
+}
+
+// parse hands messages over to VersionHandlers when dispatch is true
+func (p *Parser) parse(dispatch bool) error {
+  p.delegate = nil
+
   hdr, err := p.parseHeader()
+  if err == syslogparser.ErrVersionUnsupported && dispatch && p.VersionPolicy == DISPATCH_VERSION {
+    if handler, ok := p.VersionHandlers[hdr.version]; ok {
+      return p.dispatch(handler)
+    }
+  }
+
   if err != nil {
-    return err
+    return p.parseError(err)
   }
 
   p.header = hdr
 
-  sd, err := p.parseStructuredData()
+  p.field = syslogparser.FIELD_SD
+  sd, elements, err := p.parseStructuredData()
   if err != nil {
-    return err
+    return p.parseError(err)
   }
 
   p.structuredData = sd
+  p.sdElements = elements
   p.cursor++
+  p.field = syslogparser.FIELD_MSG
 
   if p.cursor < p.l {
-    p.message = string(p.buff[p.cursor:])
+    msg, err := p.parseMsg()
+    if err != nil {
+      return p.parseError(err)
+    }
+
+    p.message = msg
+  }
+
+  p.parseSuccessful = true
+  return nil
+}
+
+// dispatch parses the message with the parser built by handler
+func (p *Parser) dispatch(handler syslogparser.ParserFactory) error {
+  delegate := handler(&p.buff)
+  if err := delegate.Parse(); err != nil {
+    return err
   }
 
+  p.delegate = delegate
   p.parseSuccessful = true
   return nil
 }
 
 func (p *Parser) Dump() syslogparser.LogParts {
+  if p.delegate != nil {
+    return p.delegate.Dump()
+  }
+
   return syslogparser.LogParts{
     "priority":        p.header.priority.P,
     "facility":        p.header.priority.F.Value,
@@ -115,34 +321,66 @@ func (p *Parser) Dump() syslogparser.LogParts {
     "proc_id":         p.header.procId,
     "msg_id":          p.header.msgId,
     "structured_data": p.structuredData,
+    "structured_data_elements": p.sdElements,
     "message":         p.message,
   }
 }
 
 func (p *Parser) Message() message.IMessage {
+  if p.delegate != nil {
+    return p.delegate.Message()
+  }
+
   if ! p.parseSuccessful {
     return message.NewUnparsableMessage(&p.buff)
   } else {
-    return &Rfc5424Message{
-      rawMsg: &p.buff,
-      ts: p.header.timestamp,
-      pid: p.header.procId,
-      facility: message.Facility(p.header.priority.F.Value),
-      severity: message.Severity(p.header.priority.S.Value),
-      hostname: p.header.hostname,
-      message: p.message,
-      appName: p.header.appName,
-      version: p.header.version,
-      msgId: p.header.msgId,
-      structuredData: p.structuredData,
-    }
+    msg := &Rfc5424Message{}
+    p.fillMessage(msg, &p.buff)
+    return msg
+  }
+}
+
+func (p *Parser) fillMessage(msg *Rfc5424Message, rawMsg *[]byte) {
+  *msg = Rfc5424Message{
+    rawMsg: rawMsg,
+    ts: p.header.timestamp,
+    pid: p.header.procId,
+    facility: message.Facility(p.header.priority.F.Value),
+    severity: message.Severity(p.header.priority.S.Value),
+    hostname: p.header.hostname,
+    message: p.message,
+    appName: p.header.appName,
+    version: p.header.version,
+    msgId: p.header.msgId,
+    structuredData: p.structuredData,
+    sdElements: p.sdElements,
+    timestampInfo: p.header.timestampInfo,
+    declaredUTF8: p.declaredUTF8,
   }
 }
 
+func (p *Parser) receiveTime() time.Time {
+  if p.TimeFunction == nil {
+    return time.Now().UTC()
+  }
+
+  return p.TimeFunction().UTC()
+}
+
+func (p *Parser) str(b []byte) string {
+  return toString(b, p.zeroCopy)
+}
+
+// parseError locates err at the field and position the parser stopped at
+func (p *Parser) parseError(err error) error {
+  return syslogparser.NewPositionError(FORMAT, p.field, p.cursor, err)
+}
+
 // HEADER = PRI VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID
 func (p *Parser) parseHeader() (header, error) {
   hdr := header{}
 
+  p.field = syslogparser.FIELD_PRI
   pri, err := p.parsePriority()
   if err != nil {
     return hdr, err
@@ -150,11 +388,20 @@ func (p *Parser) parseHeader() (header, error) {
 
   hdr.priority = pri
 
+  p.field = syslogparser.FIELD_VERSION
+  from := p.cursor
   ver, err := p.parseVersion()
   if err != nil {
     return hdr, err
   }
   hdr.version = ver
+
+  if ver != 1 && p.VersionPolicy != ACCEPT_VERSION_AS_1 {
+    p.cursor = from
+    return hdr, syslogparser.ErrVersionUnsupported
+  }
+
+  p.field = syslogparser.FIELD_TIMESTAMP
   p.cursor++
   if p.cursor >= p.l {
     return hdr, syslogparser.ErrEOL
@@ -167,6 +414,8 @@ func (p *Parser) parseHeader() (header, error) {
   }
 
   hdr.timestamp = ts
+  hdr.timestampInfo = p.timestampInfo
+  p.field = syslogparser.FIELD_HOSTNAME
   p.cursor++
 
   if p.cursor >= p.l {
@@ -179,6 +428,7 @@ func (p *Parser) parseHeader() (header, error) {
   }
 
   hdr.hostname = host
+  p.field = syslogparser.FIELD_APP_NAME
   p.cursor++
   if p.cursor >= p.l {
     return hdr, syslogparser.ErrEOL
@@ -190,6 +440,7 @@ func (p *Parser) parseHeader() (header, error) {
   }
 
   hdr.appName = appName
+  p.field = syslogparser.FIELD_PROCID
   p.cursor++
   if p.cursor >= p.l {
     return hdr, syslogparser.ErrEOL
@@ -201,6 +452,7 @@ func (p *Parser) parseHeader() (header, error) {
   }
 
   hdr.procId = procId
+  p.field = syslogparser.FIELD_MSGID
   p.cursor++
   if p.cursor >= p.l {
     return hdr, syslogparser.ErrEOL
@@ -212,6 +464,7 @@ func (p *Parser) parseHeader() (header, error) {
   }
 
   hdr.msgId = msgId
+  p.field = syslogparser.FIELD_SD
   p.cursor++
   if p.cursor >= p.l {
     return hdr, syslogparser.ErrEOL
@@ -228,12 +481,28 @@ func (p *Parser) parseVersion() (int, error) {
   return syslogparser.ParseVersion(p.buff, &p.cursor, p.l)
 }
 
-// https://tools.ietf.org/html/rfc5424#section-6.2.3
+/* https://tools.ietf.org/html/rfc5424#section-6.2.3
+   A leap second, 23:59:60 UTC, is read as the first second of the next
+   minute as time.Date does, 23:59:60.5Z being 00:00:00.5Z the next day, and
+   flagged in TimestampInfo. */
 func (p *Parser) parseTimestamp() (time.Time, error) {
   var ts time.Time
+  from := p.cursor
+
+  if p.cursor >= p.l {
+    return ts, syslogparser.ErrEOL
+  }
 
   if p.buff[p.cursor] == NILVALUE {
     p.cursor++
+    p.timestampInfo = message.TimestampInfo{
+      Raw:    p.str(p.buff[from:p.cursor]),
+      Absent: true,
+    }
+
+    if p.ReceiveTimeWhenAbsent {
+      ts = p.receiveTime()
+    }
     return ts, nil
   }
 
@@ -242,6 +511,10 @@ func (p *Parser) parseTimestamp() (time.Time, error) {
     return ts, err
   }
 
+  if p.cursor >= p.l {
+    return ts, syslogparser.ErrEOL
+  }
+
   if p.buff[p.cursor] != 'T' {
     return ts, ErrInvalidTimeFormat
   }
@@ -253,11 +526,6 @@ func (p *Parser) parseTimestamp() (time.Time, error) {
     return ts, syslogparser.ErrTimestampUnknownFormat
   }
 
-  nSec, err := toNSec(ft.pt.secFrac)
-  if err != nil {
-    return ts, err
-  }
-
   ts = time.Date(
     fd.year,
     time.Month(fd.month),
@@ -265,35 +533,87 @@ func (p *Parser) parseTimestamp() (time.Time, error) {
     ft.pt.hour,
     ft.pt.minute,
     ft.pt.seconds,
-    nSec,
+    ft.pt.nSec,
     ft.loc,
   )
 
+  // Leap seconds are inserted at the end of a UTC day
+  if ft.pt.leapSecond {
+    if utc := ts.Add(-time.Second).UTC(); utc.Hour() != 23 || utc.Minute() != 59 {
+      return time.Time{}, ErrSecondInvalid
+    }
+  }
+
+  _, offset := ts.Zone()
+  p.timestampInfo = message.TimestampInfo{
+    Raw:        p.str(p.buff[from:p.cursor]),
+    Precision:  ft.pt.precision,
+    Offset:     offset,
+    LeapSecond: ft.pt.leapSecond,
+  }
+
   return ts, nil
 }
 
+/* MSG = MSG-ANY / MSG-UTF8, MSG-UTF8 = BOM UTF-8-STRING
+   The BOM is not part of the returned MSG. */
+func (p *Parser) parseMsg() (string, error) {
+  msg := p.buff[p.cursor:p.l]
+
+  if !bytes.HasPrefix(msg, bom) {
+    if p.Decoder != nil {
+      return p.Decoder(msg)
+    }
+
+    return p.str(msg), nil
+  }
+
+  p.declaredUTF8 = true
+  p.cursor += len(bom)
+  msg = msg[len(bom):]
+
+  switch p.UTF8Policy {
+  case REPLACE_INVALID_UTF8:
+    if !utf8.Valid(msg) {
+      return strings.ToValidUTF8(string(msg), string(utf8.RuneError)), nil
+    }
+  case REJECT_INVALID_UTF8:
+    if i := invalidUTF8(msg); i >= 0 {
+      p.cursor += i
+      return "", ErrInvalidUTF8
+    }
+  }
+
+  return p.str(msg), nil
+}
+
 // HOSTNAME = NILVALUE / 1*255PRINTUSASCII
 func (p *Parser) parseHostname() (string, error) {
-  return syslogparser.ParseHostname(p.buff, &p.cursor, p.l)
+  hostname, err := syslogparser.ParseHostnameBytes(p.buff, &p.cursor, p.l)
+  return p.str(hostname), err
 }
 
 // APP-NAME = NILVALUE / 1*48PRINTUSASCII
 func (p *Parser) parseAppName() (string, error) {
-  return parseUpToLen(p.buff, &p.cursor, p.l, 48, ErrInvalidAppName)
+  appName, err := parseUpToLen(p.buff, &p.cursor, p.l, MAX_APP_NAME_LEN, ErrInvalidAppName)
+  return p.str(appName), err
 }
 
 // PROCID = NILVALUE / 1*128PRINTUSASCII
 func (p *Parser) parseProcId() (string, error) {
-  return parseUpToLen(p.buff, &p.cursor, p.l, 128, ErrInvalidProcId)
+  procId, err := parseUpToLen(p.buff, &p.cursor, p.l, MAX_PROCID_LEN, ErrInvalidProcId)
+  return p.str(procId), err
 }
 
 // MSGID = NILVALUE / 1*32PRINTUSASCII
 func (p *Parser) parseMsgId() (string, error) {
-  return parseUpToLen(p.buff, &p.cursor, p.l, 32, ErrInvalidMsgId)
+  msgId, err := parseUpToLen(p.buff, &p.cursor, p.l, MAX_MSGID_LEN, ErrInvalidMsgId)
+  return p.str(msgId), err
 }
 
-func (p *Parser) parseStructuredData() (string, error) {
-  return parseStructuredData(p.buff, &p.cursor, p.l)
+func (p *Parser) parseStructuredData() (string, []SDElement, error) {
+  sd, elements, err := scanStructuredData(p.buff, &p.cursor, p.l, p.sdElements[:0], p.zeroCopy)
+  return p.str(sd), elements, err
 }
 
 // ----------------------------------------------
@@ -311,6 +631,10 @@ func parseFullDate(buff []byte, cursor *int, l int) (fullDate, error) {
     return fd, err
   }
 
+  if *cursor >= l {
+    return fd, syslogparser.ErrEOL
+  }
+
   if buff[*cursor] != '-' {
     return fd, syslogparser.ErrTimestampUnknownFormat
   }
@@ -322,6 +646,10 @@ func parseFullDate(buff []byte, cursor *int, l int) (fullDate, error) {
     return fd, err
   }
 
+  if *cursor >= l {
+    return fd, syslogparser.ErrEOL
+  }
+
   if buff[*cursor] != '-' {
     return fd, syslogparser.ErrTimestampUnknownFormat
   }
@@ -380,7 +708,7 @@ func parseDay(buff []byte, cursor *int, l int) (int, error) {
 
 // FULL-TIME = PARTIAL-TIME TIME-OFFSET
 func parseFullTime(buff []byte, cursor *int, l int) (fullTime, error) {
-  var loc = new(time.Location)
+  var loc *time.Location
   var ft fullTime
 
   pt, err := parsePartialTime(buff, cursor, l)
@@ -410,6 +738,10 @@ func parsePartialTime(buff []byte, cursor *int, l int) (partialTime, error) {
     return pt, err
   }
 
+  if *cursor >= l {
+    return pt, syslogparser.ErrEOL
+  }
+
   if buff[*cursor] != ':' {
     return pt, ErrInvalidTimeFormat
   }
@@ -424,24 +756,27 @@ func parsePartialTime(buff []byte, cursor *int, l int) (partialTime, error) {
   }
 
   pt = partialTime{
-    hour:    hour,
-    minute:  minute,
-    seconds: seconds,
+    hour:       hour,
+    minute:     minute,
+    seconds:    seconds,
+    leapSecond: seconds == 60,
   }
 
   // ----
 
-  if buff[*cursor] != '.' {
+  // The TIME-OFFSET reports a missing end
+  if *cursor >= l || buff[*cursor] != '.' {
     return pt, nil
   }
 
   *cursor++
 
-  secFrac, err := parseSecFrac(buff, cursor, l)
+  nSec, precision, err := parseSecFrac(buff, cursor, l)
   if err != nil {
     return pt, nil
   }
-  pt.secFrac = secFrac
+  pt.nSec = nSec
+  pt.precision = precision
 
   return pt, nil
 }
@@ -456,46 +791,42 @@ func parseMinute(buff []byte, cursor *int, l int) (int, error) {
   return syslogparser.Parse2Digits(buff, cursor, l, 0, 59, ErrMinuteInvalid)
 }
 
-// TIME-SECOND = 2DIGIT  ; 00-59
+// TIME-SECOND = 2DIGIT  ; 00-58, 59 or 60 based on leap second rules
 func parseSecond(buff []byte, cursor *int, l int) (int, error) {
-  return syslogparser.Parse2Digits(buff, cursor, l, 0, 59, ErrSecondInvalid)
+  return syslogparser.Parse2Digits(buff, cursor, l, 0, 60, ErrSecondInvalid)
 }
 
 // TIME-SECFRAC = "." 1*6DIGIT
-func parseSecFrac(buff []byte, cursor *int, l int) (float64, error) {
-  maxDigitLen := 6
-
-  max := *cursor + maxDigitLen
+// Returns the nanoseconds and the number of digits read, up to MAX_SECFRAC_DIGITS
+func parseSecFrac(buff []byte, cursor *int, l int) (int, int, error) {
   from := *cursor
   to := from
+  nSec := 0
 
-  for to = from; to < max; to++ {
-    if to >= l {
-      break
-    }
+  for to < l && to-from < MAX_SECFRAC_DIGITS && syslogparser.IsDigit(buff[to]) {
+    nSec = nSec*10 + int(buff[to]-'0')
+    to++
+  }
 
-    c := buff[to]
-    if !syslogparser.IsDigit(c) {
-      break
-    }
+  digits := to - from
+  if digits == 0 {
+    return 0, 0, ErrSecFracInvalid
   }
 
-  sub := string(buff[from:to])
-  if len(sub) == 0 {
-    return 0, ErrSecFracInvalid
+  for i := digits; i < 9; i++ {
+    nSec *= 10
   }
 
-  secFrac, err := strconv.ParseFloat("0."+sub, 64)
   *cursor = to
-  if err != nil {
-    return 0, ErrSecFracInvalid
-  }
 
-  return secFrac, nil
+  return nSec, digits, nil
 }
 
 // TIME-OFFSET = "Z" / TIME-NUMOFFSET
 func parseTimeOffset(buff []byte, cursor *int, l int) (*time.Location, error) {
+  if *cursor >= l {
+    return nil, syslogparser.ErrEOL
+  }
 
   if buff[*cursor] == 'Z' {
     *cursor++
@@ -507,7 +838,7 @@ func parseTimeOffset(buff []byte, cursor *int, l int) (*time.Location, error) {
 
 // TIME-NUMOFFSET  = ("+" / "-") TIME-HOUR ":" TIME-MINUTE
 func parseNumericalTimeOffset(buff []byte, cursor *int, l int) (*time.Location, error) {
-  var loc = new(time.Location)
+  var loc *time.Location
 
   sign := buff[*cursor]
 
@@ -522,13 +853,37 @@ func parseNumericalTimeOffset(buff []byte, cursor *int, l int) (*time.Location,
     return loc, err
   }
 
-  tzStr := fmt.Sprintf("%s%02d:%02d", string(sign), hour, minute)
-  tmpTs, err := time.Parse("-07:00", tzStr)
-  if err != nil {
-    return loc, err
+  offset := hour*60*60 + minute*60
+  if sign == '-' {
+    offset = -offset
   }
 
-  return tmpTs.Location(), nil
+  return fixedZone(offset), nil
+}
+
+var (
+  zonesMu sync.RWMutex
+  zones   = make(map[int]*time.Location)
+)
+
+// Locations are shared between messages with the same offset
+func fixedZone(offset int) *time.Location {
+  zonesMu.RLock()
+  loc, ok := zones[offset]
+  zonesMu.RUnlock()
+
+  if ok {
+    return loc
+  }
+
+  // Same location as the one built by time.Parse for a numerical offset
+  loc = time.FixedZone("", offset)
+
+  zonesMu.Lock()
+  zones[offset] = loc
+  zonesMu.Unlock()
+
+  return loc
 }
 
 func getHourMinute(buff []byte, cursor *int, l int) (int, int, error) {
@@ -537,6 +892,10 @@ func getHourMinute(buff []byte, cursor *int, l int) (int, int, error) {
     return 0, 0, err
   }
 
+  if *cursor >= l {
+    return 0, 0, syslogparser.ErrEOL
+  }
+
   if buff[*cursor] != ':' {
     return 0, 0, ErrInvalidTimeFormat
   }
@@ -551,66 +910,216 @@ func getHourMinute(buff []byte, cursor *int, l int) (int, int, error) {
   return hour, minute, nil
 }
 
-func toNSec(sec float64) (int, error) {
-  _, frac := math.Modf(sec)
-  fracStr := strconv.FormatFloat(frac, 'f', 9, 64)
-  fracInt, err := strconv.Atoi(fracStr[2:])
-  if err != nil {
-    return 0, err
-  }
-
-  return fracInt, nil
-}
-
 // ------------------------------------------------
 // https://tools.ietf.org/html/rfc5424#section-6.3
 // ------------------------------------------------
 
-func parseStructuredData(buff []byte, cursor *int, l int) (string, error) {
-  var sdData string
-  var found bool
+// STRUCTURED-DATA = NILVALUE / 1*SD-ELEMENT
+func parseStructuredData(buff []byte, cursor *int, l int) (string, []SDElement, error) {
+  sd, elements, err := scanStructuredData(buff, cursor, l, nil, false)
+  return string(sd), elements, err
+}
+
+/* scanStructuredData appends the SD-ELEMENTs to elements, reusing the
+   storage it holds past its length. */
+func scanStructuredData(buff []byte, cursor *int, l int, elements []SDElement, zeroCopy bool) ([]byte, []SDElement, error) {
+  if *cursor >= l {
+    return nil, nil, ErrNoStructuredData
+  }
 
   if buff[*cursor] == NILVALUE {
     *cursor++
-    return "-", nil
+    return buff[*cursor-1 : *cursor], elements, nil
   }
 
   if buff[*cursor] != '[' {
-    return sdData, ErrNoStructuredData
+    return nil, nil, ErrNoStructuredData
   }
 
+  from := *cursor
+
+  for *cursor < l && buff[*cursor] == '[' {
+    n := len(elements)
+    if n < cap(elements) {
+      elements = elements[:n+1]
+    } else {
+      elements = append(elements, SDElement{})
+    }
+
+    err := parseSDElement(buff, cursor, l, &elements[n], zeroCopy)
+    if err != nil {
+      return nil, nil, err
+    }
+  }
+
+  if *cursor < l && buff[*cursor] != ' ' {
+    return nil, nil, ErrSDNoSpace
+  }
+
+  return buff[from:*cursor], elements, nil
+}
+
+// SD-ELEMENT = "[" SD-ID *(SP SD-PARAM) "]"
+func parseSDElement(buff []byte, cursor *int, l int, elem *SDElement, zeroCopy bool) error {
+  // skip "["
+  *cursor++
+
+  id, err := parseSDName(buff, cursor, l, ErrInvalidSDID)
+  if err != nil {
+    return err
+  }
+
+  elem.ID = toString(id, zeroCopy)
+  elem.Params = elem.Params[:0]
+
+  for {
+    if *cursor >= l {
+      return ErrSDElementNoEnd
+    }
+
+    switch buff[*cursor] {
+    case ']':
+      *cursor++
+      if len(elem.Params) == 0 {
+        // Keep elements without params comparable to freshly built ones
+        elem.Params = nil
+      }
+      return nil
+    case ' ':
+      *cursor++
+    default:
+      return ErrInvalidSDParam
+    }
+
+    n := len(elem.Params)
+    if n < cap(elem.Params) {
+      elem.Params = elem.Params[:n+1]
+    } else {
+      elem.Params = append(elem.Params, SDParam{})
+    }
+
+    err := parseSDParam(buff, cursor, l, &elem.Params[n], zeroCopy)
+    if err != nil {
+      return err
+    }
+  }
+}
+
+// SD-PARAM = PARAM-NAME "=" %d34 PARAM-VALUE %d34
+func parseSDParam(buff []byte, cursor *int, l int, param *SDParam, zeroCopy bool) error {
+  name, err := parseSDName(buff, cursor, l, ErrInvalidSDParam)
+  if err != nil {
+    return err
+  }
+
+  if *cursor >= l || buff[*cursor] != '=' {
+    return ErrInvalidSDParam
+  }
+
+  *cursor++
+
+  if *cursor >= l || buff[*cursor] != '"' {
+    return ErrSDParamNoValue
+  }
+
+  *cursor++
+
+  value, err := parseSDParamValue(buff, cursor, l, zeroCopy)
+  if err != nil {
+    return err
+  }
+
+  param.Name = toString(name, zeroCopy)
+  param.Value = value
+
+  return nil
+}
+
+// SD-NAME = 1*32PRINTUSASCII ; except '=', SP, ']', %d34 (")
+func parseSDName(buff []byte, cursor *int, l int, e error) ([]byte, error) {
   from := *cursor
   to := from
 
   for to = from; to < l; to++ {
-    if found {
+    c := buff[to]
+    if c == '=' || c == ' ' || c == ']' || c == '"' {
       break
     }
 
-    b := buff[to]
+    if c < 33 || c > 126 {
+      return nil, e
+    }
+  }
 
-    if b == ']' {
-      switch t := to + 1; {
-      case t == l:
-        found = true
-      case t <= l && buff[t] == ' ':
-        found = true
-      }
+  if to == from || to-from > MAX_SD_NAME_LEN {
+    return nil, e
+  }
+
+  *cursor = to
+
+  return buff[from:to], nil
+}
+
+// PARAM-VALUE = UTF-8-STRING ; characters '"', '\' and ']' MUST be escaped.
+// A backslash followed by any other character is kept as is, see
+// https://tools.ietf.org/html/rfc5424#section-6.3.3
+func parseSDParamValue(buff []byte, cursor *int, l int, zeroCopy bool) (string, error) {
+  from := *cursor
+
+  // Values without escapes, the common case, are a plain slice of buff
+  for to := from; to < l; to++ {
+    c := buff[to]
+
+    if c == '"' {
+      *cursor = to + 1
+      return toString(buff[from:to], zeroCopy), nil
+    }
+
+    if c == '\\' {
+      return unescapeSDParamValue(buff, cursor, l)
     }
   }
 
-  if found {
-    *cursor = to
-    return string(buff[from:to]), nil
+  return "", ErrSDParamNoEnd
+}
+
+func unescapeSDParamValue(buff []byte, cursor *int, l int) (string, error) {
+  var value []byte
+
+  from := *cursor
+  escaped := false
+
+  for to := from; to < l; to++ {
+    c := buff[to]
+
+    if escaped {
+      if c != '"' && c != '\\' && c != ']' {
+        value = append(value, '\\')
+      }
+
+      value = append(value, c)
+      escaped = false
+      continue
+    }
+
+    switch c {
+    case '\\':
+      escaped = true
+    case '"':
+      *cursor = to + 1
+      return string(value), nil
+    default:
+      value = append(value, c)
+    }
   }
 
-  return sdData, ErrNoStructuredData
+  return "", ErrSDParamNoEnd
 }
 
-func parseUpToLen(buff []byte, cursor *int, l int, maxLen int, e error) (string, error) {
+func parseUpToLen(buff []byte, cursor *int, l int, maxLen int, e error) ([]byte, error) {
   var to int
   var found bool
-  var result string
+  var result []byte
 
   max := *cursor + maxLen
 
@@ -622,7 +1131,7 @@ func parseUpToLen(buff []byte, cursor *int, l int, maxLen int, e error) (string,
   }
 
   if found {
-    result = string(buff[*cursor:to])
+    result = buff[*cursor:to]
   }
 
   *cursor = to
@@ -631,5 +1140,30 @@ func parseUpToLen(buff []byte, cursor *int, l int, maxLen int, e error) (string,
     return result, nil
   }
 
-  return "", e
+  return nil, e
+}
+
+// invalidUTF8 gives the offset of the first invalid UTF-8 sequence in b, -1 if none
+func invalidUTF8(b []byte) int {
+  if utf8.Valid(b) {
+    return -1
+  }
+
+  for i := 0; i < len(b); {
+    r, size := utf8.DecodeRune(b[i:])
+    if r == utf8.RuneError && size == 1 {
+      return i
+    }
+    i += size
+  }
+
+  return -1
+}
+
+func toString(b []byte, zeroCopy bool) string {
+  if zeroCopy {
+    return zerocopy.String(b)
+  }
+
+  return string(b)
 }
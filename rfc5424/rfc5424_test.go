@@ -1,8 +1,10 @@
 package rfc5424
 
 import (
+  "errors"
   "fmt"
   "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/message"
   . "github.com/scalingdata/check"
   "testing"
   "time"
@@ -25,7 +27,7 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
     `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry...`,
 
     // STRUCTURED-DATA Only
-    `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource= "Application" eventID="1011"][examplePriority@32473 class="high"]`,
+    `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`,
   }
 
   tmpTs, err := time.Parse("-07:00", "-07:00")
@@ -43,6 +45,7 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
       "proc_id":         "-",
       "msg_id":          "ID47",
       "structured_data": "-",
+      "structured_data_elements": []SDElement(nil),
       "message":         "'su root' failed for lonvick on /dev/pts/8",
     },
     syslogparser.LogParts{
@@ -56,6 +59,7 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
       "proc_id":         "8710",
       "msg_id":          "-",
       "structured_data": "-",
+      "structured_data_elements": []SDElement(nil),
       "message":         "%% It's time to make the do-nuts.",
     },
     syslogparser.LogParts{
@@ -69,6 +73,16 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
       "proc_id":         "-",
       "msg_id":          "ID47",
       "structured_data": `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"]`,
+      "structured_data_elements": []SDElement{
+        SDElement{
+          ID: "exampleSDID@32473",
+          Params: []SDParam{
+            SDParam{Name: "iut", Value: "3"},
+            SDParam{Name: "eventSource", Value: "Application"},
+            SDParam{Name: "eventID", Value: "1011"},
+          },
+        },
+      },
       "message":         "An application event log entry...",
     },
     syslogparser.LogParts{
@@ -81,7 +95,23 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
       "app_name":        "evntslog",
       "proc_id":         "-",
       "msg_id":          "ID47",
-      "structured_data": `[exampleSDID@32473 iut="3" eventSource= "Application" eventID="1011"][examplePriority@32473 class="high"]`,
+      "structured_data": `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`,
+      "structured_data_elements": []SDElement{
+        SDElement{
+          ID: "exampleSDID@32473",
+          Params: []SDParam{
+            SDParam{Name: "iut", Value: "3"},
+            SDParam{Name: "eventSource", Value: "Application"},
+            SDParam{Name: "eventID", Value: "1011"},
+          },
+        },
+        SDElement{
+          ID: "examplePriority@32473",
+          Params: []SDParam{
+            SDParam{Name: "class", Value: "high"},
+          },
+        },
+      },
       "message":         "",
     },
   }
@@ -109,6 +139,224 @@ func (s *Rfc5424TestSuite) TestParser_Valid(c *C) {
   }
 }
 
+func (s *Rfc5424TestSuite) TestParser_TimestampInfo(c *C) {
+  fixtures := []struct {
+    buff string
+    ts   time.Time
+    info message.TimestampInfo
+  }{
+    {
+      "<165>1 2003-10-11T22:14:15.003Z host app - - -",
+      time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+      message.TimestampInfo{Raw: "2003-10-11T22:14:15.003Z", Precision: 3},
+    },
+    {
+      "<165>1 2003-08-24T05:14:15.000003-07:00 host app - - -",
+      time.Date(2003, time.August, 24, 12, 14, 15, 3000, time.UTC),
+      message.TimestampInfo{Raw: "2003-08-24T05:14:15.000003-07:00", Precision: 6, Offset: -7 * 3600},
+    },
+    {
+      "<165>1 2003-08-24T05:14:15.123456789+05:30 host app - - -",
+      time.Date(2003, time.August, 23, 23, 44, 15, 123456789, time.UTC),
+      message.TimestampInfo{Raw: "2003-08-24T05:14:15.123456789+05:30", Precision: 9, Offset: 5*3600 + 30*60},
+    },
+    {
+      "<165>1 - host app - - -",
+      time.Time{},
+      message.TimestampInfo{Raw: "-", Absent: true},
+    },
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    p := NewParser(&buff)
+
+    c.Assert(p.Parse(), IsNil, Commentf(f.buff))
+    c.Assert(p.Message().TimeStamp().Equal(f.ts), Equals, true, Commentf(f.buff))
+    c.Assert(p.Message().(IMessage).TimestampInfo(), Equals, f.info, Commentf(f.buff))
+  }
+}
+
+func (s *Rfc5424TestSuite) TestParser_Errors(c *C) {
+  fixtures := []struct {
+    buff   string
+    field  string
+    offset int
+    cause  error
+  }{
+    {"<34", syslogparser.FIELD_PRI, 0, syslogparser.ErrPriorityNoEnd},
+    {"<34>Oct 11 22:14:15 host su: msg", syslogparser.FIELD_VERSION, 4, syslogparser.ErrVersionNotFound},
+    {"<34>01 - host su - - -", syslogparser.FIELD_VERSION, 4, syslogparser.ErrVersionInvalid},
+    {"<34>1 ", syslogparser.FIELD_TIMESTAMP, 6, syslogparser.ErrEOL},
+    {"<34>1 2003-13-11T22:14:15.003Z host su - ID47 - msg", syslogparser.FIELD_TIMESTAMP, 13, ErrMonthInvalid},
+    {"<34>1 2003-10-11T22:14:15.003Z host su - ID47 [id bar] msg", syslogparser.FIELD_SD, 53, ErrInvalidSDParam},
+  }
+
+  for _, f := range fixtures {
+    buff := []byte(f.buff)
+    err := NewParser(&buff).Parse()
+    c.Assert(errors.Is(err, f.cause), Equals, true, Commentf("%q: %v", f.buff, err))
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(err, &parseErr), Equals, true)
+    c.Assert(parseErr.Format, Equals, FORMAT)
+    c.Assert(parseErr.Field, Equals, f.field, Commentf("%q", f.buff))
+    c.Assert(parseErr.Offset, Equals, f.offset, Commentf("%q", f.buff))
+  }
+}
+
+func (s *Rfc5424TestSuite) TestParser_Truncated(c *C) {
+  fixtures := []string{
+    "<34>1 2003",
+    "<34>1 2003-10",
+    "<34>1 2003-10-11",
+    "<34>1 2003-10-11T22",
+    "<34>1 2003-10-11T22:14",
+    "<34>1 2003-10-11T22:14:15",
+    "<34>1 2003-10-11T22:14:15.003",
+    "<34>1 2003-10-11T22:14:15.003+02",
+    "<34>1 2003-10-11T22:14:15.003+02:00",
+  }
+
+  for _, f := range fixtures {
+    // Without spare capacity, reading past the end panics
+    buff := []byte(f)
+    buff = buff[:len(buff):len(buff)]
+    err := NewParser(&buff).Parse()
+    c.Assert(err, NotNil, Commentf("%q", f))
+
+    var parseErr *syslogparser.PositionError
+    c.Assert(errors.As(err, &parseErr), Equals, true)
+    c.Assert(parseErr.Field, Matches, syslogparser.FIELD_TIMESTAMP+"|"+syslogparser.FIELD_HOSTNAME, Commentf("%q", f))
+  }
+}
+
+func (s *Rfc5424TestSuite) TestParser_VersionPolicy(c *C) {
+  buff := []byte("<34>12 - host su - - - msg")
+  p := NewParser(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().(IMessage).Version(), Equals, 12)
+  c.Assert(p.Message().Message(), Equals, "msg")
+
+  p.VersionPolicy = REJECT_VERSION
+  p.Reset(&buff)
+  err := p.Parse()
+  c.Assert(errors.Is(err, syslogparser.ErrVersionUnsupported), Equals, true)
+
+  var parseErr *syslogparser.PositionError
+  c.Assert(errors.As(err, &parseErr), Equals, true)
+  c.Assert(parseErr.Field, Equals, syslogparser.FIELD_VERSION)
+  c.Assert(parseErr.Offset, Equals, 4)
+
+  // Version 1 is still parsed
+  v1 := []byte("<34>1 - host su - - - msg")
+  p.Reset(&v1)
+  c.Assert(p.Parse(), IsNil)
+}
+
+func (s *Rfc5424TestSuite) TestParser_VersionDispatch(c *C) {
+  buff := []byte("<34>12 - host su - - - msg")
+  p := NewParser(&buff)
+  p.VersionPolicy = DISPATCH_VERSION
+
+  // No handler for version 12
+  err := p.Parse()
+  c.Assert(errors.Is(err, syslogparser.ErrVersionUnsupported), Equals, true)
+
+  var handled *[]byte
+  p.VersionHandlers = map[int]syslogparser.ParserFactory{
+    12: func(buff *[]byte) syslogparser.LogParser {
+      handled = buff
+      return NewParser(buff)
+    },
+  }
+  p.Reset(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(string(*handled), Equals, string(buff))
+  c.Assert(p.Message().(IMessage).Version(), Equals, 12)
+  c.Assert(p.Dump()["version"], Equals, 12)
+
+  // ParseInto does not dispatch
+  var msg Rfc5424Message
+  err = p.ParseInto(&buff, &msg)
+  c.Assert(errors.Is(err, syslogparser.ErrVersionUnsupported), Equals, true)
+
+  v1 := []byte("<34>1 - host su - - - msg")
+  c.Assert(p.ParseInto(&v1, &msg), IsNil)
+  c.Assert(msg.Version(), Equals, 1)
+}
+
+func (s *Rfc5424TestSuite) TestParser_BOM(c *C) {
+  buff := []byte("<34>1 - host su - - - \xEF\xBB\xBFcaf\xC3\xA9")
+  p := NewParser(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  msg := p.Message().(IMessage)
+  c.Assert(msg.Message(), Equals, "caf\u00e9")
+  c.Assert(msg.DeclaredUTF8(), Equals, true)
+
+  buff = []byte("<34>1 - host su - - - caf\xC3\xA9")
+  p.Reset(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  msg = p.Message().(IMessage)
+  c.Assert(msg.Message(), Equals, "caf\u00e9")
+  c.Assert(msg.DeclaredUTF8(), Equals, false)
+}
+
+func (s *Rfc5424TestSuite) TestParser_UTF8Policy(c *C) {
+  buff := []byte("<34>1 - host su - - - \xEF\xBB\xBFcaf\xE9 cr\xC3\xA8me")
+  p := NewParser(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().Message(), Equals, "caf\xE9 cr\u00e8me")
+
+  p.UTF8Policy = REPLACE_INVALID_UTF8
+  p.Reset(&buff)
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().Message(), Equals, "caf\uFFFD cr\u00e8me")
+
+  p.UTF8Policy = REJECT_INVALID_UTF8
+  p.Reset(&buff)
+  err := p.Parse()
+  c.Assert(errors.Is(err, ErrInvalidUTF8), Equals, true)
+
+  var parseErr *syslogparser.PositionError
+  c.Assert(errors.As(err, &parseErr), Equals, true)
+  c.Assert(parseErr.Field, Equals, syslogparser.FIELD_MSG)
+  c.Assert(parseErr.Offset, Equals, 28)
+
+  // MSGs without BOM are octets, not checked
+  buff = []byte("<34>1 - host su - - - caf\xE9")
+  p.Reset(&buff)
+  c.Assert(p.Parse(), IsNil)
+}
+
+func (s *Rfc5424TestSuite) TestParser_Decoder(c *C) {
+  latin1 := func(b []byte) (string, error) {
+    runes := make([]rune, len(b))
+    for i, c := range b {
+      runes[i] = rune(c)
+    }
+    return string(runes), nil
+  }
+
+  buff := []byte("<34>1 - host su - - - caf\xE9")
+  p := NewParser(&buff)
+  p.Decoder = latin1
+
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().Message(), Equals, "caf\u00e9")
+
+  // MSGs declared UTF-8 are not decoded
+  buff = []byte("<34>1 - host su - - - \xEF\xBB\xBFcaf\xC3\xA9")
+  p.Reset(&buff)
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().Message(), Equals, "caf\u00e9")
+}
+
 func (s *Rfc5424TestSuite) TestParseHeader_Valid(c *C) {
   ts := time.Date(2003, time.October, 11, 22, 14, 15, 3*10e5, time.UTC)
   tsString := "2003-10-11T22:14:15.003Z"
@@ -135,6 +383,9 @@ func (s *Rfc5424TestSuite) TestParseHeader_Valid(c *C) {
     fmt.Sprintf(headerFmt, tsString, hostname, appName, procId, nilValue, msgBody),
   }
 
+  tsInfo := message.TimestampInfo{Raw: tsString, Precision: 3}
+  nilTsInfo := message.TimestampInfo{Raw: nilValue, Absent: true}
+
   pri := syslogparser.Priority{
     P: 165,
     F: syslogparser.Facility{Value: 20},
@@ -144,63 +395,69 @@ func (s *Rfc5424TestSuite) TestParseHeader_Valid(c *C) {
   expected := []header{
     // HEADER complete
     header{
-      priority:  pri,
-      version:   1,
-      timestamp: ts,
-      hostname:  hostname,
-      appName:   appName,
-      procId:    procId,
-      msgId:     msgId,
+      priority:      pri,
+      version:       1,
+      timestamp:     ts,
+      timestampInfo: tsInfo,
+      hostname:      hostname,
+      appName:       appName,
+      procId:        procId,
+      msgId:         msgId,
     },
     // TIMESTAMP as NILVALUE
     header{
-      priority:  pri,
-      version:   1,
-      timestamp: *new(time.Time),
-      hostname:  hostname,
-      appName:   appName,
-      procId:    procId,
-      msgId:     msgId,
+      priority:      pri,
+      version:       1,
+      timestamp:     *new(time.Time),
+      timestampInfo: nilTsInfo,
+      hostname:      hostname,
+      appName:       appName,
+      procId:        procId,
+      msgId:         msgId,
     },
     // HOSTNAME as NILVALUE
     header{
-      priority:  pri,
-      version:   1,
-      timestamp: ts,
-      hostname:  nilValue,
-      appName:   appName,
-      procId:    procId,
-      msgId:     msgId,
+      priority:      pri,
+      version:       1,
+      timestamp:     ts,
+      timestampInfo: tsInfo,
+      hostname:      nilValue,
+      appName:       appName,
+      procId:        procId,
+      msgId:         msgId,
     },
     // APP-NAME as NILVALUE
     header{
-      priority:  pri,
-      version:   1,
-      timestamp: ts,
-      hostname:  hostname,
-      appName:   nilValue,
-      procId:    procId,
-      msgId:     msgId,
+      priority:      pri,
+      version:       1,
+      timestamp:     ts,
+      timestampInfo: tsInfo,
+      hostname:      hostname,
+      appName:       nilValue,
+      procId:        procId,
+      msgId:         msgId,
     },
     // PROCID as NILVALUE
     header{
-      priority:  pri,
-      version:   1,
-      timestamp: ts,
-      hostname:  hostname,
-      appName:   appName,
-      procId:    nilValue,
-      msgId:     msgId,
+      priority:      pri,
+      version:       1,
+      timestamp:     ts,
+      timestampInfo: tsInfo,
+      hostname:      hostname,
+      appName:       appName,
+      procId:        nilValue,
+      msgId:         msgId,
     },
     // MSGID as NILVALUE
     header{
-      priority:  pri,
-      version:   1,
-      timestamp: ts,
-      hostname:  hostname,
-      appName:   appName,
-      procId:    procId,
-      msgId:     nilValue,
+      priority:      pri,
+      version:       1,
+      timestamp:     ts,
+      timestampInfo: tsInfo,
+      hostname:      hostname,
+      appName:       appName,
+      procId:        procId,
+      msgId:         nilValue,
     },
   }
 
@@ -254,10 +511,22 @@ func (s *Rfc5424TestSuite) TestParseTimestamp_MicroSeconds(c *C) {
 }
 
 func (s *Rfc5424TestSuite) TestParseTimestamp_NanoSeconds(c *C) {
-  buff := []byte("2003-08-24T05:14:15.000000003-07:00")
+  tz := "-07:00"
+  buff := []byte("2003-08-24T05:14:15.000000003" + tz)
+
+  tmpTs, err := time.Parse("-07:00", tz)
+  c.Assert(err, IsNil)
+
+  ts := time.Date(2003, time.August, 24, 5, 14, 15, 3, tmpTs.Location())
+
+  s.assertTimestamp(c, ts, buff, len(buff), nil)
+}
+
+func (s *Rfc5424TestSuite) TestParseTimestamp_TooManyDigits(c *C) {
+  buff := []byte("2003-08-24T05:14:15.0000000003-07:00")
   ts := new(time.Time)
 
-  s.assertTimestamp(c, *ts, buff, 26, syslogparser.ErrTimestampUnknownFormat)
+  s.assertTimestamp(c, *ts, buff, 29, syslogparser.ErrTimestampUnknownFormat)
 }
 
 func (s *Rfc5424TestSuite) TestParseTimestamp_NilValue(c *C) {
@@ -267,6 +536,55 @@ func (s *Rfc5424TestSuite) TestParseTimestamp_NilValue(c *C) {
   s.assertTimestamp(c, *ts, buff, 1, nil)
 }
 
+func (s *Rfc5424TestSuite) TestParseTimestamp_LeapSecond(c *C) {
+  buff := []byte("2016-12-31T23:59:60.5Z")
+  ts := time.Date(2017, time.January, 1, 0, 0, 0, 500000000, time.UTC)
+
+  s.assertTimestamp(c, ts, buff, len(buff), nil)
+
+  // Leap seconds end a UTC day, whatever the time zone
+  buff = []byte("2017-01-01T05:29:60+05:30")
+  tmpTs, err := time.Parse("-07:00", "+05:30")
+  c.Assert(err, IsNil)
+  ts = time.Date(2017, time.January, 1, 5, 30, 0, 0, tmpTs.Location())
+
+  s.assertTimestamp(c, ts, buff, len(buff), nil)
+
+  buff = []byte("2016-12-31T23:59:60+01:00")
+  s.assertTimestamp(c, time.Time{}, buff, len(buff), ErrSecondInvalid)
+}
+
+func (s *Rfc5424TestSuite) TestParser_LeapSecond(c *C) {
+  buff := []byte("<165>1 2016-12-31T23:59:60Z host app - - -")
+  p := NewParser(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  msg := p.Message().(IMessage)
+  c.Assert(msg.TimeStamp(), Equals, time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC))
+  c.Assert(msg.TimestampInfo().LeapSecond, Equals, true)
+  c.Assert(msg.TimestampInfo().Absent, Equals, false)
+}
+
+func (s *Rfc5424TestSuite) TestParser_AbsentTimestamp(c *C) {
+  buff := []byte("<165>1 - host app - - -")
+  p := NewParser(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  msg := p.Message().(IMessage)
+  c.Assert(msg.TimeStamp().IsZero(), Equals, true)
+  c.Assert(msg.TimestampInfo().Absent, Equals, true)
+
+  received := time.Date(2015, time.October, 11, 22, 14, 15, 0, time.FixedZone("JST", 9*3600))
+  p.ReceiveTimeWhenAbsent = true
+  p.TimeFunction = func() time.Time { return received }
+  p.Reset(&buff)
+
+  c.Assert(p.Parse(), IsNil)
+  msg = p.Message().(IMessage)
+  c.Assert(msg.TimeStamp(), Equals, received.UTC())
+  c.Assert(msg.TimestampInfo().Absent, Equals, true)
+}
+
 func (s *Rfc5424TestSuite) TestFindNextSpace_NoSpace(c *C) {
   buff := []byte("aaaaaa")
 
@@ -481,7 +799,7 @@ func (s *Rfc5424TestSuite) TestParseSecond_InvalidRange(c *C) {
 
   // ----
 
-  buff = []byte("60")
+  buff = []byte("61")
 
   s.assertParseSecond(c, expected, buff, 2, ErrSecondInvalid)
 }
@@ -495,34 +813,34 @@ func (s *Rfc5424TestSuite) TestParseSecond_Valid(c *C) {
 
 func (s *Rfc5424TestSuite) TestParseSecFrac_InvalidString(c *C) {
   buff := []byte("azerty")
-  expected := 0.0
+  expected := 0
 
   s.assertParseSecFrac(c, expected, buff, 0, ErrSecFracInvalid)
 }
 
 func (s *Rfc5424TestSuite) TestParseSecFrac_NanoSeconds(c *C) {
-  buff := []byte("123456789")
-  expected := 0.123456
+  buff := []byte("1234567891")
+  expected := 123456789
 
-  s.assertParseSecFrac(c, expected, buff, 6, nil)
+  s.assertParseSecFrac(c, expected, buff, 9, nil)
 }
 
 func (s *Rfc5424TestSuite) TestParseSecFrac_Valid(c *C) {
   buff := []byte("0")
 
-  expected := 0.0
+  expected := 0
   s.assertParseSecFrac(c, expected, buff, 1, nil)
 
   buff = []byte("52")
-  expected = 0.52
+  expected = 520000000
   s.assertParseSecFrac(c, expected, buff, 2, nil)
 
   buff = []byte("003")
-  expected = 0.003
+  expected = 3000000
   s.assertParseSecFrac(c, expected, buff, 3, nil)
 
   buff = []byte("000003")
-  expected = 0.000003
+  expected = 3000
   s.assertParseSecFrac(c, expected, buff, 6, nil)
 }
 
@@ -579,7 +897,8 @@ func (s *Rfc5424TestSuite) TestParsePartialTime_Valid(c *C) {
     hour:    5,
     minute:  14,
     seconds: 15,
-    secFrac: 0.000003,
+    nSec:    3000,
+    precision: 6,
   }
 
   c.Assert(err, IsNil)
@@ -602,7 +921,8 @@ func (s *Rfc5424TestSuite) TestParseFullTime_Valid(c *C) {
       hour:    5,
       minute:  14,
       seconds: 15,
-      secFrac: 0.000003,
+      nSec:    3000,
+      precision: 6,
     },
     loc: tmpTs.Location(),
   }
@@ -612,27 +932,6 @@ func (s *Rfc5424TestSuite) TestParseFullTime_Valid(c *C) {
   c.Assert(cursor, Equals, 21)
 }
 
-func (s *Rfc5424TestSuite) TestToNSec(c *C) {
-  fixtures := []float64{
-    0.52,
-    0.003,
-    0.000003,
-  }
-
-  expected := []int{
-    520000000,
-    3000000,
-    3000,
-  }
-
-  c.Assert(len(fixtures), Equals, len(expected))
-  for i, f := range fixtures {
-    obtained, err := toNSec(f)
-    c.Assert(err, IsNil)
-    c.Assert(obtained, Equals, expected[i])
-  }
-}
-
 func (s *Rfc5424TestSuite) TestParseAppName_Valid(c *C) {
   buff := []byte("su ")
   appName := "su"
@@ -687,27 +986,107 @@ func (s *Rfc5424TestSuite) TestParseStructuredData_NilValue(c *C) {
 }
 
 func (s *Rfc5424TestSuite) TestParseStructuredData_SingleStructuredData(c *C) {
-  sdData := `[exampleSDID@32473 iut="3" eventSource="Application"eventID="1011"]`
+  sdData := `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"]`
   buff := []byte(sdData)
 
   s.assertParseSdName(c, sdData, buff, len(buff), nil)
 }
 
 func (s *Rfc5424TestSuite) TestParseStructuredData_MultipleStructuredData(c *C) {
-  sdData := `[exampleSDID@32473 iut="3" eventSource="Application"eventID="1011"][examplePriority@32473 class="high"]`
+  sdData := `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`
   buff := []byte(sdData)
 
   s.assertParseSdName(c, sdData, buff, len(buff), nil)
 }
 
 func (s *Rfc5424TestSuite) TestParseStructuredData_MultipleStructuredDataInvalid(c *C) {
-  a := `[exampleSDID@32473 iut="3" eventSource="Application"eventID="1011"]`
+  a := `[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"]`
   sdData := a + ` [examplePriority@32473 class="high"]`
   buff := []byte(sdData)
 
   s.assertParseSdName(c, a, buff, len(a), nil)
 }
 
+func (s *Rfc5424TestSuite) TestParseStructuredData_Elements(c *C) {
+  buff := []byte(`[exampleSDID@32473 iut="3" eventSource="Application"][examplePriority@32473 class="high"][origin]`)
+  expected := []SDElement{
+    SDElement{
+      ID: "exampleSDID@32473",
+      Params: []SDParam{
+        SDParam{Name: "iut", Value: "3"},
+        SDParam{Name: "eventSource", Value: "Application"},
+      },
+    },
+    SDElement{
+      ID: "examplePriority@32473",
+      Params: []SDParam{
+        SDParam{Name: "class", Value: "high"},
+      },
+    },
+    SDElement{
+      ID: "origin",
+    },
+  }
+
+  s.assertParseSdElements(c, expected, buff, len(buff), nil)
+}
+
+func (s *Rfc5424TestSuite) TestParseStructuredData_EscapedValues(c *C) {
+  buff := []byte(`[id quote="a\"b" backslash="a\\b" bracket="a\]b" other="a\nb" space="a b"] msg`)
+  expected := []SDElement{
+    SDElement{
+      ID: "id",
+      Params: []SDParam{
+        SDParam{Name: "quote", Value: `a"b`},
+        SDParam{Name: "backslash", Value: `a\b`},
+        SDParam{Name: "bracket", Value: `a]b`},
+        SDParam{Name: "other", Value: `a\nb`},
+        SDParam{Name: "space", Value: `a b`},
+      },
+    },
+  }
+
+  s.assertParseSdElements(c, expected, buff, len(buff)-4, nil)
+}
+
+func (s *Rfc5424TestSuite) TestParseStructuredData_Invalid(c *C) {
+  fixtures := []string{
+    `exampleSDID@32473]`,
+    `[]`,
+    `[ iut="3"]`,
+    `[exampleSDIDexampleSDIDexampleSDID@32473 iut="3"]`,
+    `[exampleSDID@32473 iut="3"eventID="1011"]`,
+    `[exampleSDID@32473 iut= "3"]`,
+    `[exampleSDID@32473 iut=3]`,
+    `[exampleSDID@32473 ="3"]`,
+    `[exampleSDID@32473 iut="3]`,
+    `[exampleSDID@32473 iut="3"`,
+    `[exampleSDID@32473 iut="3"]foo`,
+  }
+
+  expected := []error{
+    ErrNoStructuredData,
+    ErrInvalidSDID,
+    ErrInvalidSDID,
+    ErrInvalidSDID,
+    ErrInvalidSDParam,
+    ErrSDParamNoValue,
+    ErrSDParamNoValue,
+    ErrInvalidSDParam,
+    ErrSDParamNoEnd,
+    ErrSDElementNoEnd,
+    ErrSDNoSpace,
+  }
+
+  c.Assert(len(fixtures), Equals, len(expected))
+  for i, f := range fixtures {
+    buff := []byte(f)
+    cursor := 0
+    _, _, err := parseStructuredData(buff, &cursor, len(buff))
+    c.Assert(err, Equals, expected[i])
+  }
+}
+
 // -------------
 
 func (s *Rfc5424TestSuite) BenchmarkParseTimestamp(c *C) {
@@ -815,10 +1194,10 @@ func (s *Rfc5424TestSuite) assertParseSecond(c *C, second int, b []byte, expC in
   c.Assert(cursor, Equals, expC)
 }
 
-func (s *Rfc5424TestSuite) assertParseSecFrac(c *C, secFrac float64, b []byte, expC int, e error) {
+func (s *Rfc5424TestSuite) assertParseSecFrac(c *C, nSec int, b []byte, expC int, e error) {
   cursor := 0
-  obtained, err := parseSecFrac(b, &cursor, len(b))
-  c.Assert(obtained, Equals, secFrac)
+  obtained, _, err := parseSecFrac(b, &cursor, len(b))
+  c.Assert(obtained, Equals, nSec)
   c.Assert(err, Equals, e)
   c.Assert(cursor, Equals, expC)
 }
@@ -852,9 +1231,102 @@ func (s *Rfc5424TestSuite) assertParseMsgId(c *C, msgId string, b []byte, expC i
 
 func (s *Rfc5424TestSuite) assertParseSdName(c *C, sdData string, b []byte, expC int, e error) {
   cursor := 0
-  obtained, err := parseStructuredData(b, &cursor, len(b))
+  obtained, _, err := parseStructuredData(b, &cursor, len(b))
 
   c.Assert(err, Equals, e)
   c.Assert(obtained, Equals, sdData)
   c.Assert(cursor, Equals, expC)
 }
+
+func (s *Rfc5424TestSuite) assertParseSdElements(c *C, elements []SDElement, b []byte, expC int, e error) {
+  cursor := 0
+  _, obtained, err := parseStructuredData(b, &cursor, len(b))
+
+  c.Assert(err, Equals, e)
+  c.Assert(obtained, DeepEquals, elements)
+  c.Assert(cursor, Equals, expC)
+}
+
+func (s *Rfc5424TestSuite) TestParseInto_Reuse(c *C) {
+  first := []byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"][origin ip="192.0.2.1"] An application event`)
+  second := []byte(`<34>1 2003-10-11T22:14:15.003-07:00 otherhost su 123 ID48 [meta seq="\]1"] 'su root' failed`)
+  bad := []byte("FOO BAR BAZ")
+
+  p := NewParser(&first)
+  var msg Rfc5424Message
+
+  c.Assert(p.ParseInto(&first, &msg), IsNil)
+  c.Assert(msg.Hostname(), Equals, "mymachine.example.com")
+  c.Assert(msg.Message(), Equals, "An application event")
+  c.Assert(msg.SDElements(), DeepEquals, []SDElement{
+    SDElement{
+      ID: "exampleSDID@32473",
+      Params: []SDParam{
+        SDParam{Name: "iut", Value: "3"},
+        SDParam{Name: "eventSource", Value: "Application"},
+      },
+    },
+    SDElement{
+      ID: "origin",
+      Params: []SDParam{SDParam{Name: "ip", Value: "192.0.2.1"}},
+    },
+  })
+
+  c.Assert(p.ParseInto(&second, &msg), IsNil)
+  c.Assert(msg.Hostname(), Equals, "otherhost")
+  c.Assert(msg.MsgId(), Equals, "ID48")
+  c.Assert(msg.ProcId(), Equals, "123")
+  c.Assert(msg.StructuredData(), Equals, `[meta seq="\]1"]`)
+  c.Assert(msg.SDElements(), DeepEquals, []SDElement{
+    SDElement{
+      ID: "meta",
+      Params: []SDParam{SDParam{Name: "seq", Value: "]1"}},
+    },
+  })
+  c.Assert(string(*msg.RawMessage()), Equals, string(second))
+
+  c.Assert(p.ParseInto(&bad, &msg), NotNil)
+  c.Assert(msg.Hostname(), Equals, "")
+  c.Assert(msg.SDElements(), HasLen, 0)
+
+  p.Reset(&first)
+  c.Assert(p.Parse(), IsNil)
+  c.Assert(p.Message().Hostname(), Equals, "mymachine.example.com")
+}
+
+func (s *Rfc5424TestSuite) TestParseInto_ZeroAllocations(c *C) {
+  buff := []byte(`<165>1 2003-08-24T05:14:15.000003-07:00 mymachine.example.com evntslog 8710 ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry...`)
+  p := NewParser(&buff)
+  var msg Rfc5424Message
+
+  allocs := testing.AllocsPerRun(100, func() {
+    if err := p.ParseInto(&buff, &msg); err != nil {
+      panic(err)
+    }
+  })
+  c.Assert(allocs, Equals, 0.0)
+}
+
+func (s *Rfc5424TestSuite) BenchmarkParseInto(c *C) {
+  buff := []byte(`<165>1 2003-08-24T05:14:15.000003-07:00 mymachine.example.com evntslog 8710 ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry...`)
+  p := NewParser(&buff)
+  var msg Rfc5424Message
+
+  for i := 0; i < c.N; i++ {
+    if err := p.ParseInto(&buff, &msg); err != nil {
+      panic(err)
+    }
+  }
+}
+
+func (s *Rfc5424TestSuite) BenchmarkNewParser(c *C) {
+  buff := []byte(`<165>1 2003-08-24T05:14:15.000003-07:00 mymachine.example.com evntslog 8710 ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] An application event log entry...`)
+
+  for i := 0; i < c.N; i++ {
+    p := NewParser(&buff)
+    if err := p.Parse(); err != nil {
+      panic(err)
+    }
+    p.Message()
+  }
+}
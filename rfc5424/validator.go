@@ -0,0 +1,284 @@
+package rfc5424
+
+import (
+  "bytes"
+  "fmt"
+  "github.com/scalingdata/syslogparser"
+  "strings"
+  "unicode/utf8"
+)
+
+var (
+  // https://tools.ietf.org/html/rfc5424#section-7
+  registeredSDIDs = map[string]bool{
+    "timeQuality": true,
+    "origin":      true,
+    "meta":        true,
+  }
+)
+
+type validator struct {
+  buff       []byte
+  cursor     int
+  l          int
+  violations []syslogparser.Violation
+}
+
+/* Validate checks buff against the rules of RFC 5424 and reports every
+   violation found. It goes on after a violation as long as the next fields
+   can still be found, a message without violations is parsable. */
+func Validate(buff []byte) []syslogparser.Violation {
+  v := &validator{
+    buff: buff,
+    l:    len(buff),
+  }
+
+  v.validate()
+  return v.violations
+}
+
+func (v *validator) validate() {
+  violations, err := syslogparser.ValidatePriority(FORMAT, v.buff, &v.cursor, v.l)
+  v.violations = append(v.violations, violations...)
+  if err != nil {
+    return
+  }
+
+  if !v.validateVersion() || !v.validateTimestamp() {
+    return
+  }
+
+  fields := []struct {
+    name   string
+    maxLen int
+  }{
+    {syslogparser.FIELD_HOSTNAME, MAX_HOSTNAME_LEN},
+    {syslogparser.FIELD_APP_NAME, MAX_APP_NAME_LEN},
+    {syslogparser.FIELD_PROCID, MAX_PROCID_LEN},
+    {syslogparser.FIELD_MSGID, MAX_MSGID_LEN},
+  }
+
+  for _, f := range fields {
+    from := v.cursor
+    token, ok := v.nextToken(f.name)
+    if !ok {
+      return
+    }
+
+    v.validateHeaderField(f.name, from, token, f.maxLen)
+  }
+
+  if !v.validateStructuredData() {
+    return
+  }
+
+  v.validateMsg()
+}
+
+func (v *validator) report(field string, offset int, format string, args ...interface{}) {
+  v.violations = append(v.violations, syslogparser.Violation{
+    Format: FORMAT,
+    Field:  field,
+    Offset: offset,
+    Reason: fmt.Sprintf(format, args...),
+  })
+}
+
+/* nextToken returns the header field at the cursor and moves past the SP
+   ending it, ok is false when the field is missing. */
+func (v *validator) nextToken(field string) ([]byte, bool) {
+  if v.cursor >= v.l {
+    v.report(field, v.cursor, "Missing %s", field)
+    return nil, false
+  }
+
+  from := v.cursor
+  for v.cursor < v.l && v.buff[v.cursor] != ' ' {
+    v.cursor++
+  }
+
+  token := v.buff[from:v.cursor]
+  if v.cursor < v.l {
+    v.cursor++
+  }
+
+  return token, true
+}
+
+// VERSION = NONZERO-DIGIT 0*2DIGIT
+func (v *validator) validateVersion() bool {
+  from := v.cursor
+  version := 0
+
+  for v.cursor < v.l && syslogparser.IsDigit(v.buff[v.cursor]) {
+    version = version*10 + int(v.buff[v.cursor]-'0')
+    v.cursor++
+  }
+
+  digits := v.cursor - from
+  if digits == 0 {
+    v.report(syslogparser.FIELD_VERSION, from, "%s", syslogparser.ErrVersionNotFound)
+    return false
+  }
+
+  if v.buff[from] == '0' || digits > 3 {
+    v.report(syslogparser.FIELD_VERSION, from, "%s", syslogparser.ErrVersionInvalid)
+  } else if version != 1 {
+    v.report(syslogparser.FIELD_VERSION, from, "Unsupported version %d", version)
+  }
+
+  if v.cursor >= v.l || v.buff[v.cursor] != ' ' {
+    v.report(syslogparser.FIELD_VERSION, v.cursor, "%s", syslogparser.ErrNoSpace)
+    return false
+  }
+
+  v.cursor++
+  return true
+}
+
+func (v *validator) validateTimestamp() bool {
+  from := v.cursor
+  token, ok := v.nextToken(syslogparser.FIELD_TIMESTAMP)
+  if !ok {
+    return false
+  }
+
+  if len(token) == 1 && token[0] == NILVALUE {
+    return true
+  }
+
+  // Timestamps ending the message are reported as missing HOSTNAME instead
+  end := from + len(token)
+  if end >= v.l {
+    return true
+  }
+
+  p := &Parser{
+    buff:   v.buff,
+    cursor: from,
+    l:      end,
+  }
+
+  _, err := p.parseTimestamp()
+  if err != nil {
+    v.report(syslogparser.FIELD_TIMESTAMP, from, "%s", err)
+  } else if p.cursor != end {
+    v.report(syslogparser.FIELD_TIMESTAMP, p.cursor, "Trailing characters in timestamp")
+  } else if p.timestampInfo.Precision > MAX_PRECISION {
+    v.report(syslogparser.FIELD_TIMESTAMP, from+len("2006-01-02T15:04:05."), "TIME-SECFRAC longer than %d digits", MAX_PRECISION)
+  }
+
+  return true
+}
+
+// HOSTNAME, APP-NAME, PROCID and MSGID are NILVALUE / 1*<maxLen>PRINTUSASCII
+func (v *validator) validateHeaderField(field string, from int, token []byte, maxLen int) {
+  if len(token) == 0 {
+    v.report(field, from, "Empty %s", field)
+    return
+  }
+
+  if len(token) > maxLen {
+    v.report(field, from, "%s longer than %d characters", field, maxLen)
+  }
+
+  v.validatePrintUSASCII(field, from, token)
+}
+
+func (v *validator) validatePrintUSASCII(field string, from int, token []byte) {
+  for i, c := range token {
+    if c < 33 || c > 126 {
+      v.report(field, from+i, "Non PRINTUSASCII character 0x%02x", c)
+      return
+    }
+  }
+}
+
+// STRUCTURED-DATA = NILVALUE / 1*SD-ELEMENT
+func (v *validator) validateStructuredData() bool {
+  if v.cursor >= v.l {
+    v.report(syslogparser.FIELD_SD, v.cursor, "Missing %s", syslogparser.FIELD_SD)
+    return false
+  }
+
+  if v.buff[v.cursor] == NILVALUE {
+    v.cursor++
+  } else if v.buff[v.cursor] == '[' {
+    seen := make(map[string]bool)
+
+    for v.cursor < v.l && v.buff[v.cursor] == '[' {
+      from := v.cursor
+      var elem SDElement
+
+      err := parseSDElement(v.buff, &v.cursor, v.l, &elem, false)
+      if err != nil {
+        v.report(syslogparser.FIELD_SD, v.cursor, "%s", err)
+        return false
+      }
+
+      v.validateSDID(from+1, elem.ID, seen)
+    }
+  } else {
+    v.report(syslogparser.FIELD_SD, v.cursor, "%s", ErrNoStructuredData)
+    return false
+  }
+
+  if v.cursor < v.l {
+    if v.buff[v.cursor] != ' ' {
+      v.report(syslogparser.FIELD_SD, v.cursor, "%s", ErrSDNoSpace)
+      return false
+    }
+
+    v.cursor++
+  }
+
+  return true
+}
+
+/* SD-IDs are either registered with IANA or of the name@<enterprise number>
+   form, and appear at most once in a message. */
+func (v *validator) validateSDID(from int, id string, seen map[string]bool) {
+  if seen[id] {
+    v.report(syslogparser.FIELD_SD, from, "Duplicated SD-ID %s", id)
+  }
+  seen[id] = true
+
+  at := strings.IndexByte(id, '@')
+  if at < 0 {
+    if !registeredSDIDs[id] {
+      v.report(syslogparser.FIELD_SD, from, "Unregistered SD-ID %s without enterprise number", id)
+    }
+    return
+  }
+
+  enterprise := id[at+1:]
+  valid := len(enterprise) > 0 && syslogparser.IsDigit(enterprise[0])
+  for i := 0; valid && i < len(enterprise); i++ {
+    valid = syslogparser.IsDigit(enterprise[i]) || enterprise[i] == '.'
+  }
+
+  if !valid {
+    v.report(syslogparser.FIELD_SD, from, "Invalid enterprise number in SD-ID %s", id)
+  }
+}
+
+// MSG = MSG-ANY / MSG-UTF8, MSG-UTF8 = BOM UTF-8-STRING
+func (v *validator) validateMsg() {
+  msg := v.buff[v.cursor:]
+
+  if bytes.HasPrefix(msg, bom) {
+    if !utf8.Valid(msg[len(bom):]) {
+      v.report(syslogparser.FIELD_MSG, v.cursor, "MSG starts with a BOM but is not valid UTF-8")
+    }
+    return
+  }
+
+  for _, c := range msg {
+    if c >= utf8.RuneSelf {
+      if utf8.Valid(msg) {
+        v.report(syslogparser.FIELD_MSG, v.cursor, "UTF-8 MSG without BOM")
+      }
+      return
+    }
+  }
+}
@@ -0,0 +1,80 @@
+package rfc5424
+
+import (
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  "strings"
+)
+
+type ValidatorTestSuite struct {
+}
+
+var _ = Suite(&ValidatorTestSuite{})
+
+func (s *ValidatorTestSuite) TestValidate_Valid(c *C) {
+  fixtures := []string{
+    "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8",
+    `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3"][timeQuality tzKnown="1"]`,
+    "<0>1 - - - - - -",
+    "<34>1 - - - - - - \xEF\xBB\xBFunicode é",
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Validate([]byte(f)), HasLen, 0, Commentf("%q", f))
+  }
+}
+
+func (s *ValidatorTestSuite) TestValidate_Violations(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected []syslogparser.Violation
+  }{
+    {
+      "<192>2 - - - - - -",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_PRI, 1, "Priority greater than 191"},
+        {FORMAT, syslogparser.FIELD_VERSION, 5, "Unsupported version 2"},
+      },
+    },
+    {
+      "<034>1 2003-13-11T22:14:15.003Z host " + strings.Repeat("a", 49) + " - ID 47 -",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_PRI, 1, "Leading zero in priority"},
+        {FORMAT, syslogparser.FIELD_TIMESTAMP, 7, "Invalid month in timestamp"},
+        {FORMAT, syslogparser.FIELD_APP_NAME, 37, "APP-NAME longer than 48 characters"},
+        {FORMAT, syslogparser.FIELD_SD, 92, "No structured data"},
+      },
+    },
+    {
+      "<34>1 - h\x01st - - - " + `[ex@32473 a="1"][ex@32473 b="2"][custom c="3"] caf` + "\xC3\xA9",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_HOSTNAME, 9, "Non PRINTUSASCII character 0x01"},
+        {FORMAT, syslogparser.FIELD_SD, 36, "Duplicated SD-ID ex@32473"},
+        {FORMAT, syslogparser.FIELD_SD, 52, "Unregistered SD-ID custom without enterprise number"},
+        {FORMAT, syslogparser.FIELD_MSG, 66, "UTF-8 MSG without BOM"},
+      },
+    },
+    {
+      "<34>1 2003-10-11T22:14:15.123456789Z - - - - -",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_TIMESTAMP, 26, "TIME-SECFRAC longer than 6 digits"},
+      },
+    },
+    {
+      "<34>1 - host app",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_PROCID, 16, "Missing PROCID"},
+      },
+    },
+    {
+      "<34>1 - - - - - [] msg",
+      []syslogparser.Violation{
+        {FORMAT, syslogparser.FIELD_SD, 17, "Invalid SD-ID in structured data"},
+      },
+    },
+  }
+
+  for _, f := range fixtures {
+    c.Assert(Validate([]byte(f.buff)), DeepEquals, f.expected, Commentf("%q", f.buff))
+  }
+}
@@ -0,0 +1,385 @@
+// Syslog listener for UDP (RFC 5426), TCP (RFC 6587) and Unix sockets
+
+package server
+
+import (
+  "bytes"
+  "context"
+  "crypto/tls"
+  "crypto/x509"
+  "io"
+  log "github.com/scalingdata/log4go"
+  "github.com/scalingdata/syslogparser"
+  "github.com/scalingdata/syslogparser/framing"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/multiparser"
+  "net"
+  "sync"
+  "time"
+)
+
+type Transport string
+
+const (
+  UDP          Transport = "udp"
+  TCP          Transport = "tcp"
+  UnixDatagram Transport = "unixgram"
+  UnixStream   Transport = "unix"
+  TLS          Transport = "tls"
+  RELP         Transport = "relp"
+)
+
+const (
+  DEFAULT_WORKERS = 8
+)
+
+// Receive metadata attached to every message
+type Metadata struct {
+  Peer             net.Addr
+  ReceivedAt       time.Time
+  Transport        Transport
+  // Certificates presented by the peer, TLS only
+  PeerCertificates []*x509.Certificate
+}
+
+/* Handler is called from the worker pool for every received message, so it
+   must be safe for concurrent use. */
+type Handler func(msg message.IMessage, meta Metadata) error
+
+type Received struct {
+  Message  message.IMessage
+  Metadata Metadata
+}
+
+// ChannelHandler delivers messages to ch instead of calling back
+func ChannelHandler(ch chan<- Received) Handler {
+  return func(msg message.IMessage, meta Metadata) error {
+    ch <- Received{Message: msg, Metadata: meta}
+    return nil
+  }
+}
+
+type Server struct {
+  Handler       Handler
+  ParserFactory syslogparser.ParserFactory
+  /* FormatCache, when not nil, is given to the multiparser.Parsers built
+     by ParserFactory, the source of a message being the host of its peer. */
+  FormatCache   *multiparser.FormatCache
+  // Size of the pool parsing and handling messages
+  Workers       int
+  MaxFrameSize  int
+  // Framing of stream transports, auto detected per connection by default
+  Framing       framing.Method
+
+  mu            sync.Mutex
+  listeners     []streamListener
+  packetConns   []packetConn
+  conns         map[net.Conn]struct{}
+  jobs          chan job
+  readers       sync.WaitGroup
+  serving       bool
+}
+
+type streamListener struct {
+  l         net.Listener
+  transport Transport
+}
+
+type packetConn struct {
+  c         net.PacketConn
+  transport Transport
+}
+
+type job struct {
+  frame []byte
+  meta  Metadata
+}
+
+func NewServer(handler Handler) *Server {
+  return &Server{
+    Handler:       handler,
+    ParserFactory: multiparser.NewRfcParser,
+    Workers:       DEFAULT_WORKERS,
+    MaxFrameSize:  framing.DEFAULT_MAX_FRAME_SIZE,
+    Framing:       framing.AutoDetect,
+    conns:         make(map[net.Conn]struct{}),
+  }
+}
+
+func (s *Server) ListenUDP(addr string) (net.Addr, error) {
+  c, err := net.ListenPacket("udp", addr)
+  if err != nil {
+    return nil, err
+  }
+
+  s.AddPacketConn(c, UDP)
+  return c.LocalAddr(), nil
+}
+
+func (s *Server) ListenTCP(addr string) (net.Addr, error) {
+  l, err := net.Listen("tcp", addr)
+  if err != nil {
+    return nil, err
+  }
+
+  s.AddListener(l, TCP)
+  return l.Addr(), nil
+}
+
+func (s *Server) ListenUnixgram(path string) (net.Addr, error) {
+  c, err := net.ListenPacket("unixgram", path)
+  if err != nil {
+    return nil, err
+  }
+
+  s.AddPacketConn(c, UnixDatagram)
+  return c.LocalAddr(), nil
+}
+
+func (s *Server) ListenUnix(path string) (net.Addr, error) {
+  l, err := net.Listen("unix", path)
+  if err != nil {
+    return nil, err
+  }
+
+  s.AddListener(l, UnixStream)
+  return l.Addr(), nil
+}
+
+/* AddListener serves an already bound stream listener, it must be called
+   before Serve. The server owns l and closes it on shutdown. */
+func (s *Server) AddListener(l net.Listener, transport Transport) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.listeners = append(s.listeners, streamListener{l, transport})
+}
+
+/* AddPacketConn serves an already bound datagram socket, it must be called
+   before Serve. The server owns c and closes it on shutdown. */
+func (s *Server) AddPacketConn(c net.PacketConn, transport Transport) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.packetConns = append(s.packetConns, packetConn{c, transport})
+}
+
+/* Serve blocks until ctx is done, then closes every socket and returns once
+   the messages already received have been handled. */
+func (s *Server) Serve(ctx context.Context) error {
+  workers := s.Workers
+  if workers <= 0 {
+    workers = DEFAULT_WORKERS
+  }
+
+  s.mu.Lock()
+  s.jobs = make(chan job, workers)
+  s.serving = true
+  for _, l := range s.listeners {
+    s.readers.Add(1)
+    go s.acceptLoop(l)
+  }
+  for _, c := range s.packetConns {
+    s.readers.Add(1)
+    go s.readPackets(c)
+  }
+  s.mu.Unlock()
+
+  var pool sync.WaitGroup
+  for i := 0; i < workers; i++ {
+    pool.Add(1)
+    go func() {
+      defer pool.Done()
+      for j := range s.jobs {
+        s.handle(j)
+      }
+    }()
+  }
+
+  <-ctx.Done()
+
+  s.mu.Lock()
+  s.serving = false
+  for _, l := range s.listeners {
+    l.l.Close()
+  }
+  for _, c := range s.packetConns {
+    c.c.Close()
+  }
+  for c := range s.conns {
+    c.Close()
+  }
+  s.mu.Unlock()
+
+  s.readers.Wait()
+  close(s.jobs)
+  pool.Wait()
+
+  return nil
+}
+
+/* parse runs frame through the configured parser. A parser panicking on
+   a malformed frame gives an UnparsableMessage instead of taking the
+   server down. */
+func (s *Server) parse(frame []byte, meta Metadata) (msg message.IMessage) {
+  defer func() {
+    if r := recover(); r != nil {
+      log.Error("Parser panicked on message from %v: %v", meta.Peer, r)
+      msg = message.NewUnparsableMessage(&frame)
+    }
+  }()
+
+  p := s.ParserFactory(&frame)
+  if mp, ok := p.(*multiparser.Parser); ok && s.FormatCache != nil {
+    mp.Cache = s.FormatCache
+    mp.Source = peerHost(meta.Peer)
+  }
+
+  if err := p.Parse(); err != nil {
+    log.Debug("Unable to parse message due to '%s'", err)
+  }
+
+  return p.Message()
+}
+
+func (s *Server) handle(j job) {
+  msg := s.parse(j.frame, j.meta)
+  if err := s.Handler(msg, j.meta); err != nil {
+    log.Warn("Handler failed for message from %v: %s", j.meta.Peer, err)
+  }
+}
+
+// peerHost gives the host of peer without its port, which changes across connections
+func peerHost(peer net.Addr) string {
+  if peer == nil {
+    return ""
+  }
+
+  host, _, err := net.SplitHostPort(peer.String())
+  if err != nil {
+    return peer.String()
+  }
+
+  return host
+}
+
+func (s *Server) acceptLoop(l streamListener) {
+  defer s.readers.Done()
+
+  for {
+    conn, err := l.l.Accept()
+    if err != nil {
+      if !s.isServing() {
+        return
+      }
+      if ne, ok := err.(net.Error); ok && ne.Temporary() {
+        log.Warn("Temporary error accepting on %v: %s", l.l.Addr(), err)
+        time.Sleep(10 * time.Millisecond)
+        continue
+      }
+      log.Error("Error accepting on %v: %s", l.l.Addr(), err)
+      return
+    }
+
+    if !s.trackConn(conn) {
+      conn.Close()
+      return
+    }
+
+    s.readers.Add(1)
+    go s.readStream(conn, l.transport)
+  }
+}
+
+func (s *Server) readStream(conn net.Conn, transport Transport) {
+  defer s.readers.Done()
+  defer s.untrackConn(conn)
+
+  s.serveConn(conn, transport)
+}
+
+/* serveConn reads frames from conn until it is closed and hands them to the
+   worker pool. */
+func (s *Server) serveConn(conn net.Conn, transport Transport) {
+  var peerCerts []*x509.Certificate
+
+  method := s.Framing
+
+  if tlsConn, ok := conn.(*tls.Conn); ok {
+    // Handshake upfront to report authorization failures and keep the
+    // peer certificates with every message
+    if err := tlsConn.Handshake(); err != nil {
+      log.Warn("TLS handshake with %v failed: %s", conn.RemoteAddr(), err)
+      return
+    }
+    peerCerts = tlsConn.ConnectionState().PeerCertificates
+    method = framing.OctetCounting
+  }
+
+  d := framing.NewDecoder(conn, method)
+  d.MaxFrameSize = s.MaxFrameSize
+
+  for {
+    frame, err := d.Next()
+    if err == framing.ErrFrameTooLarge {
+      log.Warn("Dropping oversized frame from %v", conn.RemoteAddr())
+      continue
+    }
+    if err != nil {
+      if err != io.EOF && s.isServing() {
+        log.Warn("Closing connection from %v: %s", conn.RemoteAddr(), err)
+      }
+      return
+    }
+
+    s.jobs <- job{frame, Metadata{conn.RemoteAddr(), time.Now(), transport, peerCerts}}
+  }
+}
+
+// https://tools.ietf.org/html/rfc5426#section-3.1 : one message per datagram
+func (s *Server) readPackets(c packetConn) {
+  defer s.readers.Done()
+
+  buff := make([]byte, s.MaxFrameSize)
+  for {
+    n, addr, err := c.c.ReadFrom(buff)
+    if err != nil {
+      if !s.isServing() {
+        return
+      }
+      if ne, ok := err.(net.Error); ok && ne.Temporary() {
+        continue
+      }
+      log.Error("Error reading from %v: %s", c.c.LocalAddr(), err)
+      return
+    }
+
+    frame := bytes.TrimRight(buff[:n], "\n\x00")
+    if len(frame) == 0 {
+      continue
+    }
+
+    s.jobs <- job{append([]byte(nil), frame...), Metadata{addr, time.Now(), c.transport, nil}}
+  }
+}
+
+func (s *Server) isServing() bool {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.serving
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  if !s.serving {
+    return false
+  }
+  s.conns[conn] = struct{}{}
+  return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  delete(s.conns, conn)
+  conn.Close()
+}
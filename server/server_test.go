@@ -0,0 +1,269 @@
+package server
+
+import (
+  "context"
+  "io/ioutil"
+  . "github.com/scalingdata/check"
+  "github.com/scalingdata/syslogparser"
+  message "github.com/scalingdata/syslogparser/message"
+  "github.com/scalingdata/syslogparser/multiparser"
+  "github.com/scalingdata/syslogparser/rfc5424"
+  "net"
+  "os"
+  "path/filepath"
+  "strconv"
+  "testing"
+  "time"
+)
+
+// Hooks up gocheck into the gotest runner.
+func Test(t *testing.T) { TestingT(t) }
+
+// Runs a server per test, shared by the transport suites
+type serverFixture struct {
+  dir      string
+  received chan Received
+  server   *Server
+  cancel   context.CancelFunc
+  done     chan error
+}
+
+type ServerTestSuite struct {
+  serverFixture
+}
+
+var _ = Suite(&ServerTestSuite{})
+
+const (
+  msg3164 = "<34>Oct 11 22:14:15 mymachine su: 'su root' failed"
+  msg5424 = "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event"
+)
+
+func (s *serverFixture) SetUpTest(c *C) {
+  var err error
+  s.dir, err = ioutil.TempDir("", "syslogserver")
+  c.Assert(err, IsNil)
+
+  s.received = make(chan Received, 10)
+  s.server = NewServer(ChannelHandler(s.received))
+  s.server.Workers = 2
+}
+
+func (s *serverFixture) TearDownTest(c *C) {
+  if s.cancel != nil {
+    s.cancel()
+    c.Assert(<-s.done, IsNil)
+    s.cancel = nil
+  }
+  os.RemoveAll(s.dir)
+}
+
+func (s *ServerTestSuite) TestUDP(c *C) {
+  addr, err := s.server.ListenUDP("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("udp", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(msg5424 + "\n"))
+  c.Assert(err, IsNil)
+
+  r := s.receive(c)
+  c.Assert(r.Metadata.Transport, Equals, UDP)
+  c.Assert(r.Metadata.Peer.String(), Equals, conn.LocalAddr().String())
+  c.Assert(r.Message.Message(), Equals, "An application event")
+
+  rfcMsg, ok := r.Message.(rfc5424.IMessage)
+  c.Assert(ok, Equals, true)
+  c.Assert(rfcMsg.MsgId(), Equals, "ID47")
+}
+
+func (s *ServerTestSuite) TestUDP_TruncatedFrames(c *C) {
+  addr, err := s.server.ListenUDP("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("udp", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  frames := []struct {
+    frame      string
+    unparsable bool
+  }{
+    {"<34>1 2003-10-11", true},
+    {"<34>1 2003-10-11T22:14", true},
+    {"<34>1 2003-10-11T22:14:15.003+02", true},
+    {"<34>Oct 11 22:14:15 host su[", false},
+  }
+
+  for _, f := range frames {
+    _, err = conn.Write([]byte(f.frame))
+    c.Assert(err, IsNil)
+
+    r := s.receive(c)
+    _, unparsable := r.Message.(*message.UnparsableMessage)
+    c.Assert(unparsable, Equals, f.unparsable, Commentf(f.frame))
+  }
+
+  // Still serving
+  _, err = conn.Write([]byte(msg5424))
+  c.Assert(err, IsNil)
+  c.Assert(s.receive(c).Message.Message(), Equals, "An application event")
+}
+
+type panickingParser struct {
+  syslogparser.LogParser
+}
+
+func (p panickingParser) Parse() error {
+  panic("malformed")
+}
+
+func (s *ServerTestSuite) TestParserPanic(c *C) {
+  s.server.ParserFactory = func(buff *[]byte) syslogparser.LogParser {
+    return panickingParser{}
+  }
+  addr, err := s.server.ListenUDP("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("udp", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(msg5424))
+  c.Assert(err, IsNil)
+
+  r := s.receive(c)
+  _, unparsable := r.Message.(*message.UnparsableMessage)
+  c.Assert(unparsable, Equals, true)
+  c.Assert(string(*r.Message.RawMessage()), Equals, msg5424)
+}
+
+func (s *ServerTestSuite) TestFormatCache(c *C) {
+  s.server.FormatCache = multiparser.NewFormatCache(16, time.Minute)
+  addr, err := s.server.ListenUDP("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("udp", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  for i := 0; i < 2; i++ {
+    _, err = conn.Write([]byte(msg3164 + "\n"))
+    c.Assert(err, IsNil)
+    s.receive(c)
+  }
+
+  format, ok := s.server.FormatCache.Get("127.0.0.1")
+  c.Assert(ok, Equals, true)
+  c.Assert(format, Equals, "rfc3164")
+  c.Assert(s.server.FormatCache.Stats().Hits, Equals, uint64(1))
+}
+
+func (s *ServerTestSuite) TestTCP(c *C) {
+  addr, err := s.server.ListenTCP("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("tcp", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(strconv.Itoa(len(msg3164)) + " " + msg3164))
+  c.Assert(err, IsNil)
+
+  r := s.receive(c)
+  c.Assert(r.Metadata.Transport, Equals, TCP)
+  c.Assert(r.Metadata.Peer.String(), Equals, conn.LocalAddr().String())
+  c.Assert(r.Message.Hostname(), Equals, "mymachine")
+  c.Assert(r.Message.Message(), Equals, "'su root' failed")
+}
+
+func (s *ServerTestSuite) TestUnixStream(c *C) {
+  addr, err := s.server.ListenUnix(filepath.Join(s.dir, "stream.sock"))
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("unix", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(msg3164 + "\n" + msg5424 + "\n"))
+  c.Assert(err, IsNil)
+
+  hostnames := map[string]bool{}
+  for i := 0; i < 2; i++ {
+    r := s.receive(c)
+    c.Assert(r.Metadata.Transport, Equals, UnixStream)
+    hostnames[r.Message.Hostname()] = true
+  }
+  c.Assert(hostnames, DeepEquals, map[string]bool{"mymachine": true, "mymachine.example.com": true})
+}
+
+func (s *ServerTestSuite) TestUnixDatagram(c *C) {
+  addr, err := s.server.ListenUnixgram(filepath.Join(s.dir, "dgram.sock"))
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("unixgram", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(msg3164))
+  c.Assert(err, IsNil)
+
+  r := s.receive(c)
+  c.Assert(r.Metadata.Transport, Equals, UnixDatagram)
+  c.Assert(r.Message.Message(), Equals, "'su root' failed")
+}
+
+func (s *ServerTestSuite) TestShutdownClosesConnections(c *C) {
+  addr, err := s.server.ListenTCP("127.0.0.1:0")
+  c.Assert(err, IsNil)
+  s.serve()
+
+  conn, err := net.Dial("tcp", addr.String())
+  c.Assert(err, IsNil)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(msg3164 + "\n"))
+  c.Assert(err, IsNil)
+  s.receive(c)
+
+  s.cancel()
+  c.Assert(<-s.done, IsNil)
+  s.cancel = nil
+
+  conn.SetReadDeadline(time.Now().Add(time.Second))
+  _, err = conn.Read(make([]byte, 1))
+  c.Assert(err, NotNil)
+
+  _, err = net.Dial("tcp", addr.String())
+  c.Assert(err, NotNil)
+}
+
+// -------------
+
+func (s *serverFixture) serve() {
+  var ctx context.Context
+  ctx, s.cancel = context.WithCancel(context.Background())
+  s.done = make(chan error, 1)
+  go func() {
+    s.done <- s.server.Serve(ctx)
+  }()
+}
+
+func (s *serverFixture) receive(c *C) Received {
+  select {
+  case r := <-s.received:
+    return r
+  case <-time.After(5 * time.Second):
+    c.Fatal("Timed out waiting for a message")
+  }
+  return Received{}
+}
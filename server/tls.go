@@ -0,0 +1,197 @@
+// TLS transport as described in https://tools.ietf.org/html/rfc5425
+
+package server
+
+import (
+  "crypto"
+  "crypto/tls"
+  "crypto/x509"
+  "fmt"
+  "net"
+  "strings"
+)
+
+var (
+  ErrNoPeerCertificate = &AuthorizationError{"Peer did not present a certificate"}
+  ErrPeerNotAuthorized = &AuthorizationError{"Peer certificate matches no authorized fingerprint or subject name"}
+)
+
+type AuthorizationError struct {
+  ErrorString string
+}
+
+func (err *AuthorizationError) Error() string {
+  return err.ErrorString
+}
+
+// https://tools.ietf.org/html/rfc5425#section-4.2.2
+var fingerprintHashes = map[string]crypto.Hash{
+  "SHA-1":   crypto.SHA1,
+  "SHA-224": crypto.SHA224,
+  "SHA-256": crypto.SHA256,
+  "SHA-384": crypto.SHA384,
+  "SHA-512": crypto.SHA512,
+}
+
+/* PeerAuthorizer implements the peer authorization of RFC 5425 section 5.2 :
+   a peer is authorized if its certificate matches one of Fingerprints, or
+   if it chains up to Roots and one of its names matches SubjectNames. */
+type PeerAuthorizer struct {
+  // Fingerprints like "SHA-256:E1:2D:...", see Fingerprint
+  Fingerprints []string
+  // DNS names, "*" is allowed as the left-most label
+  SubjectNames []string
+  // CAs used to validate peers authorized by subject name, nil means system roots
+  Roots        *x509.CertPool
+}
+
+/* ListenTLS accepts RFC 5425 connections. Octet counting is mandatory on
+   TLS so the server Framing is ignored for these connections. */
+func (s *Server) ListenTLS(addr string, config *tls.Config) (net.Addr, error) {
+  l, err := tls.Listen("tcp", addr, config)
+  if err != nil {
+    return nil, err
+  }
+
+  s.AddListener(l, TLS)
+  return l.Addr(), nil
+}
+
+/* NewTLSConfig builds a server configuration presenting cert. When auth is
+   not nil clients must present a certificate authorized by it. */
+func NewTLSConfig(cert tls.Certificate, auth *PeerAuthorizer) *tls.Config {
+  config := &tls.Config{
+    Certificates: []tls.Certificate{cert},
+    MinVersion:   tls.VersionTLS12,
+  }
+
+  if auth != nil {
+    // Chain validation is done by the authorizer since self signed
+    // certificates are legitimate when authorized by fingerprint
+    config.ClientAuth = tls.RequireAnyClientCert
+    config.VerifyPeerCertificate = auth.VerifyPeerCertificate
+  }
+
+  return config
+}
+
+// Fingerprint formats the fingerprint of cert for hash, eg. "SHA-256"
+func Fingerprint(cert *x509.Certificate, hash string) (string, error) {
+  h, ok := fingerprintHashes[strings.ToUpper(hash)]
+  if !ok || !h.Available() {
+    return "", fmt.Errorf("Unsupported fingerprint hash algorithm %q", hash)
+  }
+
+  hasher := h.New()
+  hasher.Write(cert.Raw)
+  sum := hasher.Sum(nil)
+
+  pairs := make([]string, len(sum))
+  for i, b := range sum {
+    pairs[i] = fmt.Sprintf("%02X", b)
+  }
+
+  return strings.ToUpper(hash) + ":" + strings.Join(pairs, ":"), nil
+}
+
+// VerifyPeerCertificate can be used as tls.Config.VerifyPeerCertificate
+func (a *PeerAuthorizer) VerifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+  if len(rawCerts) == 0 {
+    return ErrNoPeerCertificate
+  }
+
+  certs := make([]*x509.Certificate, len(rawCerts))
+  for i, raw := range rawCerts {
+    cert, err := x509.ParseCertificate(raw)
+    if err != nil {
+      return err
+    }
+    certs[i] = cert
+  }
+
+  return a.Authorize(certs)
+}
+
+// Authorize checks the peer certificate chain, leaf first
+func (a *PeerAuthorizer) Authorize(certs []*x509.Certificate) error {
+  if len(certs) == 0 {
+    return ErrNoPeerCertificate
+  }
+
+  leaf := certs[0]
+
+  for _, expected := range a.Fingerprints {
+    idx := strings.Index(expected, ":")
+    if idx < 0 {
+      continue
+    }
+
+    obtained, err := Fingerprint(leaf, expected[:idx])
+    if err != nil {
+      continue
+    }
+
+    if strings.EqualFold(obtained, expected) {
+      return nil
+    }
+  }
+
+  if len(a.SubjectNames) == 0 {
+    return ErrPeerNotAuthorized
+  }
+
+  intermediates := x509.NewCertPool()
+  for _, cert := range certs[1:] {
+    intermediates.AddCert(cert)
+  }
+
+  _, err := leaf.Verify(x509.VerifyOptions{
+    Roots:         a.Roots,
+    Intermediates: intermediates,
+    KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+  })
+  if err != nil {
+    return err
+  }
+
+  for _, name := range peerNames(leaf) {
+    for _, pattern := range a.SubjectNames {
+      if matchSubjectName(pattern, name) {
+        return nil
+      }
+    }
+  }
+
+  return ErrPeerNotAuthorized
+}
+
+/* https://tools.ietf.org/html/rfc5425#section-5.2 : dNSName entries of
+   subjectAltName, or the subject common name when there is none. */
+func peerNames(cert *x509.Certificate) []string {
+  if len(cert.DNSNames) > 0 {
+    return cert.DNSNames
+  }
+
+  if cert.Subject.CommonName != "" {
+    return []string{cert.Subject.CommonName}
+  }
+
+  return nil
+}
+
+// "*" only matches a whole left-most label, as in "*.example.com"
+func matchSubjectName(pattern string, name string) bool {
+  pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+  name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+  if !strings.HasPrefix(pattern, "*.") {
+    return pattern == name
+  }
+
+  idx := strings.Index(name, ".")
+  if idx <= 0 {
+    return false
+  }
+
+  return pattern[1:] == name[idx:]
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+  "crypto/ecdsa"
+  "crypto/elliptic"
+  "crypto/rand"
+  "crypto/tls"
+  "crypto/x509"
+  "crypto/x509/pkix"
+  . "github.com/scalingdata/check"
+  "math/big"
+  "net"
+  "strconv"
+  "strings"
+  "time"
+)
+
+type TLSTestSuite struct {
+  serverFixture
+  ca         *x509.Certificate
+  caKey      *ecdsa.PrivateKey
+  serverCert tls.Certificate
+}
+
+var _ = Suite(&TLSTestSuite{})
+
+func (s *TLSTestSuite) SetUpSuite(c *C) {
+  s.ca, s.caKey = s.newCert(c, "Test CA", nil, nil, nil)
+  s.serverCert = s.tlsCert(s.newCert(c, "syslog.example.com", []string{"syslog.example.com"}, s.ca, s.caKey))
+}
+
+func (s *TLSTestSuite) TestFingerprintAuthorized(c *C) {
+  clientCert := s.tlsCert(s.newCert(c, "client", nil, nil, nil))
+  fingerprint, err := Fingerprint(clientCert.Leaf, "sha-256")
+  c.Assert(err, IsNil)
+
+  addr := s.listen(c, &PeerAuthorizer{Fingerprints: []string{strings.ToLower(fingerprint)}})
+
+  conn := s.dial(c, addr, clientCert)
+  defer conn.Close()
+
+  _, err = conn.Write([]byte(strconv.Itoa(len(msg3164)) + " " + msg3164))
+  c.Assert(err, IsNil)
+
+  r := s.receive(c)
+  c.Assert(r.Metadata.Transport, Equals, TLS)
+  c.Assert(r.Message.Message(), Equals, "'su root' failed")
+  c.Assert(len(r.Metadata.PeerCertificates), Equals, 1)
+  c.Assert(r.Metadata.PeerCertificates[0].Subject.CommonName, Equals, "client")
+}
+
+func (s *TLSTestSuite) TestFingerprintNotAuthorized(c *C) {
+  clientCert := s.tlsCert(s.newCert(c, "client", nil, nil, nil))
+  otherCert := s.tlsCert(s.newCert(c, "other", nil, nil, nil))
+  fingerprint, err := Fingerprint(otherCert.Leaf, "SHA-1")
+  c.Assert(err, IsNil)
+
+  addr := s.listen(c, &PeerAuthorizer{Fingerprints: []string{fingerprint}})
+  s.assertRejected(c, addr, clientCert)
+}
+
+func (s *TLSTestSuite) TestSubjectNameAuthorized(c *C) {
+  clientCert := s.tlsCert(s.newCert(c, "client", []string{"host1.logs.example.com"}, s.ca, s.caKey))
+
+  addr := s.listen(c, &PeerAuthorizer{SubjectNames: []string{"*.logs.example.com"}, Roots: s.pool()})
+
+  conn := s.dial(c, addr, clientCert)
+  defer conn.Close()
+
+  _, err := conn.Write([]byte(strconv.Itoa(len(msg5424)) + " " + msg5424))
+  c.Assert(err, IsNil)
+
+  r := s.receive(c)
+  c.Assert(r.Message.Hostname(), Equals, "mymachine.example.com")
+}
+
+func (s *TLSTestSuite) TestSubjectNameNotAuthorized(c *C) {
+  // Right name, but signed by an untrusted CA
+  otherCA, otherKey := s.newCert(c, "Other CA", nil, nil, nil)
+  untrusted := s.tlsCert(s.newCert(c, "client", []string{"host1.logs.example.com"}, otherCA, otherKey))
+  // Trusted CA, wrong name
+  wrongName := s.tlsCert(s.newCert(c, "client", []string{"host1.example.com"}, s.ca, s.caKey))
+
+  addr := s.listen(c, &PeerAuthorizer{SubjectNames: []string{"*.logs.example.com"}, Roots: s.pool()})
+  s.assertRejected(c, addr, untrusted)
+  s.assertRejected(c, addr, wrongName)
+  s.assertRejected(c, addr, tls.Certificate{})
+}
+
+func (s *TLSTestSuite) TestFingerprint(c *C) {
+  cert, _ := s.newCert(c, "client", nil, nil, nil)
+
+  obtained, err := Fingerprint(cert, "sha-1")
+  c.Assert(err, IsNil)
+  c.Assert(obtained, Matches, "SHA-1(:[0-9A-F]{2}){20}")
+
+  _, err = Fingerprint(cert, "md5")
+  c.Assert(err, NotNil)
+}
+
+func (s *TLSTestSuite) TestMatchSubjectName(c *C) {
+  c.Assert(matchSubjectName("host.example.com", "HOST.example.com."), Equals, true)
+  c.Assert(matchSubjectName("*.example.com", "host.example.com"), Equals, true)
+  c.Assert(matchSubjectName("*.example.com", "a.host.example.com"), Equals, false)
+  c.Assert(matchSubjectName("*.example.com", "example.com"), Equals, false)
+  c.Assert(matchSubjectName("host.example.com", "other.example.com"), Equals, false)
+}
+
+// -------------
+
+func (s *TLSTestSuite) listen(c *C, auth *PeerAuthorizer) net.Addr {
+  addr, err := s.server.ListenTLS("127.0.0.1:0", NewTLSConfig(s.serverCert, auth))
+  c.Assert(err, IsNil)
+  s.serve()
+  return addr
+}
+
+func (s *TLSTestSuite) dial(c *C, addr net.Addr, cert tls.Certificate) *tls.Conn {
+  config := &tls.Config{
+    RootCAs:    s.pool(),
+    ServerName: "syslog.example.com",
+  }
+  if cert.Certificate != nil {
+    config.Certificates = []tls.Certificate{cert}
+  }
+
+  conn, err := tls.Dial("tcp", addr.String(), config)
+  c.Assert(err, IsNil)
+  return conn
+}
+
+func (s *TLSTestSuite) assertRejected(c *C, addr net.Addr, cert tls.Certificate) {
+  conn := s.dial(c, addr, cert)
+  defer conn.Close()
+
+  // With TLS 1.3 the client learns about the rejection on its first read
+  conn.Write([]byte(strconv.Itoa(len(msg3164)) + " " + msg3164))
+  conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+  _, err := conn.Read(make([]byte, 1))
+  c.Assert(err, NotNil)
+
+  select {
+  case <-s.received:
+    c.Fatal("Unauthorized peer message was received")
+  case <-time.After(50 * time.Millisecond):
+  }
+}
+
+func (s *TLSTestSuite) pool() *x509.CertPool {
+  pool := x509.NewCertPool()
+  pool.AddCert(s.ca)
+  return pool
+}
+
+func (s *TLSTestSuite) tlsCert(cert *x509.Certificate, key *ecdsa.PrivateKey) tls.Certificate {
+  return tls.Certificate{
+    Certificate: [][]byte{cert.Raw},
+    PrivateKey:  key,
+    Leaf:        cert,
+  }
+}
+
+// Self signed when parent is nil
+func (s *TLSTestSuite) newCert(c *C, cn string, dnsNames []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+  key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+  c.Assert(err, IsNil)
+
+  serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+  c.Assert(err, IsNil)
+
+  template := &x509.Certificate{
+    SerialNumber:          serial,
+    Subject:               pkix.Name{CommonName: cn},
+    DNSNames:              dnsNames,
+    NotBefore:             time.Now().Add(-time.Hour),
+    NotAfter:              time.Now().Add(time.Hour),
+    KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+    ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+    BasicConstraintsValid: true,
+    IsCA:                  parent == nil,
+  }
+
+  if parent == nil {
+    parent, parentKey = template, key
+  }
+
+  der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+  c.Assert(err, IsNil)
+
+  cert, err := x509.ParseCertificate(der)
+  c.Assert(err, IsNil)
+
+  return cert, key
+}
@@ -2,9 +2,11 @@ package syslogparser
 
 import (
   "fmt"
+  "math"
   message "github.com/scalingdata/syslogparser/message"
   "strconv"
   "strings"
+  "time"
 )
 
 const (
@@ -12,6 +14,25 @@ const (
   PRI_PART_END   = '>'
 
   NO_VERSION = -1
+
+  // https://tools.ietf.org/html/rfc3164#section-4.3.3
+  DEFAULT_PRIORITY = 13
+  // Local7 facility with Debug severity
+  MAX_PRIORITY = 191
+)
+
+// Fields of a message, as reported by PositionError
+const (
+  FIELD_PRI       = "PRI"
+  FIELD_VERSION   = "VERSION"
+  FIELD_TIMESTAMP = "TIMESTAMP"
+  FIELD_HOSTNAME  = "HOSTNAME"
+  FIELD_APP_NAME  = "APP-NAME"
+  FIELD_PROCID    = "PROCID"
+  FIELD_MSGID     = "MSGID"
+  FIELD_SD        = "SD"
+  FIELD_TAG       = "TAG"
+  FIELD_MSG       = "MSG"
 )
 
 var (
@@ -24,8 +45,11 @@ var (
   ErrPriorityTooShort = &ParserError{"Priority field too short"}
   ErrPriorityTooLong  = &ParserError{"Priority field too long"}
   ErrPriorityNonDigit = &ParserError{"Non digit found in priority"}
+  ErrPriorityInvalid  = &ParserError{"Facility or severity out of range"}
 
-  ErrVersionNotFound = &ParserError{"Can not find version"}
+  ErrVersionNotFound    = &ParserError{"Can not find version"}
+  ErrVersionInvalid     = &ParserError{"Invalid version"}
+  ErrVersionUnsupported = &ParserError{"Unsupported version"}
 
   ErrTimestampUnknownFormat = &ParserError{"Timestamp format unknown"}
 )
@@ -36,10 +60,71 @@ type LogParser interface {
   Message() message.IMessage
 }
 
+// Implemented by parsers that can be reused for several messages
+type ResettableParser interface {
+  LogParser
+  Reset(buff *[]byte)
+  // Parsed fields share the memory of buff instead of copying it
+  SetZeroCopy(zeroCopy bool)
+}
+
+// Builds a parser for one message, eg. multiparser.NewRfcParser
+type ParserFactory func(buff *[]byte) LogParser
+
+// Converts text in a legacy charset such as Latin-1 to UTF-8
+type CharsetDecoder func(b []byte) (string, error)
+
+/* Confidence tells how likely a message is of a format from a cheap look at
+   its first bytes, eg. rfc5424.Detect. */
+type Confidence int
+
+const (
+  // The parser of the format would fail
+  NO_MATCH Confidence = iota
+  UNLIKELY
+  POSSIBLE
+  LIKELY
+  CERTAIN
+)
+
+/* ParserError is the cause of a parse failure, eg. ErrPriorityNoEnd, the
+   parsers returning it wrapped in a PositionError. */
 type ParserError struct {
   ErrorString string
 }
 
+/* PositionError tells where a parser gave up on a message : Err is the cause,
+   usually one of the ParserError variables, so errors.Is(err, ErrPriorityNoEnd)
+   holds for a PositionError wrapping it. */
+type PositionError struct {
+  // Format of the parser that failed, eg. rfc5424.FORMAT
+  Format string
+  // One of the FIELD_XXX constants
+  Field  string
+  // Byte offset in the message where parsing stopped
+  Offset int
+  Err    error
+}
+
+func NewPositionError(format string, field string, offset int, err error) *PositionError {
+  return &PositionError{
+    Format: format,
+    Field:  field,
+    Offset: offset,
+    Err:    err,
+  }
+}
+
+/* Violation is a departure from the RFCs reported by a validator such as
+   rfc5424.Validate, the message may still be parsable. */
+type Violation struct {
+  Format string
+  // One of the FIELD_XXX constants
+  Field  string
+  Offset int
+  Reason string
+}
+
 type Priority struct {
   P int
   F Facility
@@ -88,12 +173,7 @@ func ParsePriority(buff []byte, cursor *int, l int) (Priority, error) {
     }
 
     if IsDigit(c) {
-      v, e := strconv.Atoi(string(c))
-      if e != nil {
-        return pri, e
-      }
-
-      priDigit = (priDigit * 10) + v
+      priDigit = (priDigit * 10) + int(c-'0')
     } else {
       return pri, ErrPriorityNonDigit
     }
@@ -104,33 +184,118 @@ func ParsePriority(buff []byte, cursor *int, l int) (Priority, error) {
   return pri, ErrPriorityNoEnd
 }
 
-// https://tools.ietf.org/html/rfc5424#section-6.2.2
+/* https://tools.ietf.org/html/rfc5424#section-6.2.2
+   VERSION = NONZERO-DIGIT 0*2DIGIT
+   The cursor is left at the start of the version on errors. Whether the
+   version is supported is up to the caller. */
 func ParseVersion(buff []byte, cursor *int, l int) (int, error) {
-  if *cursor >= l {
+  from := *cursor
+
+  if from >= l || !IsDigit(buff[from]) {
     return NO_VERSION, ErrVersionNotFound
   }
 
-  c := buff[*cursor]
-  *cursor++
+  if buff[from] == '0' {
+    return NO_VERSION, ErrVersionInvalid
+  }
+
+  version := 0
+  to := from
+  for to < l && IsDigit(buff[to]) {
+    if to-from == 3 {
+      return NO_VERSION, ErrVersionInvalid
+    }
+
+    version = version*10 + int(buff[to]-'0')
+    to++
+  }
+
+  *cursor = to
+  return version, nil
+}
+
+/* ValidatePriority reports the violations of the PRI part, err is set when
+   it can not be parsed at all and validation can not go further. */
+func ValidatePriority(format string, buff []byte, cursor *int, l int) ([]Violation, error) {
+  var violations []Violation
+  from := *cursor
+
+  pri, err := ParsePriority(buff, cursor, l)
+  if err != nil {
+    violations = append(violations, Violation{format, FIELD_PRI, from, err.Error()})
+    return violations, err
+  }
 
-  // XXX : not a version, not an error though as RFC 3164 does not support it
-  if !IsDigit(c) {
-    return NO_VERSION, nil
+  // "<" PRIVAL ">", PRIVAL being 1*3DIGIT without leading zeros
+  if *cursor-from > 3 && buff[from+1] == '0' {
+    violations = append(violations, Violation{format, FIELD_PRI, from + 1, "Leading zero in priority"})
   }
 
-  v, e := strconv.Atoi(string(c))
-  if e != nil {
-    *cursor--
-    return NO_VERSION, e
+  if pri.P > MAX_PRIORITY {
+    violations = append(violations, Violation{format, FIELD_PRI, from + 1, fmt.Sprintf("Priority greater than %d", MAX_PRIORITY)})
   }
 
-  return v, nil
+  return violations, nil
 }
 
 func IsDigit(c byte) bool {
   return c >= '0' && c <= '9'
 }
 
+// StartsWithFullDate tells if b starts like a FULL-DATE, YYYY-MM-DD, without checking the values
+func StartsWithFullDate(b []byte) bool {
+  if len(b) < 10 || b[4] != '-' || b[7] != '-' {
+    return false
+  }
+
+  for _, i := range [...]int{0, 1, 2, 3, 5, 6, 8, 9} {
+    if !IsDigit(b[i]) {
+      return false
+    }
+  }
+
+  return true
+}
+
+/* InferYear gives ts, a timestamp sent without its year, in the year
+   putting it closest to now. */
+func InferYear(ts time.Time, now time.Time) time.Time {
+  /* Compute the event timestamp this year, next year and last year.
+     This covers cases where an event crosses December->January, and
+     the sender's clock is ahead of ours and the event goes January-> December */
+
+  newTs := time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
+    ts.Second(), ts.Nanosecond(), ts.Location())
+  lastYearTs := time.Date(now.Year()-1, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
+    ts.Second(), ts.Nanosecond(), ts.Location())
+  nextYearTs := time.Date(now.Year()+1, ts.Month(), ts.Day(), ts.Hour(), ts.Minute(),
+    ts.Second(), ts.Nanosecond(), ts.Location())
+
+  /* Take the time in seconds between the current date and each candidate timestamp */
+  lastYearDiff := float64(now.Unix() - lastYearTs.Unix())
+  nextYearDiff := float64(nextYearTs.Unix() - now.Unix())
+  thisYearDiff := math.Abs(float64(now.Unix() - newTs.Unix()))
+
+  /* Set the event timestamp to the candidate which is closest to today's date */
+  if lastYearDiff < nextYearDiff && lastYearDiff < thisYearDiff {
+    return lastYearTs
+  } else if nextYearDiff < lastYearDiff && nextYearDiff < thisYearDiff {
+    return nextYearTs
+  }
+
+  return newTs
+}
+
+// ComputePriority builds the PRI value from a facility and a severity, it
+// fails if either one is out of the range defined by the RFCs.
+func ComputePriority(f message.Facility, s message.Severity) (Priority, error) {
+  if f < message.Kernel || f > message.Local7 || s < message.Emergency || s > message.Debug {
+    return newPriority(DEFAULT_PRIORITY), ErrPriorityInvalid
+  }
+
+  return newPriority(int(f)*8 + int(s)), nil
+}
+
 func newPriority(p int) Priority {
   // The Priority value is calculated by first multiplying the Facility
   // number by 8 and then adding the numerical value of the Severity.
@@ -179,6 +344,12 @@ func Parse2Digits(buff []byte, cursor *int, l int, min int, max int, e error) (i
 }
 
 func ParseHostname(buff []byte, cursor *int, l int) (string, error) {
+  hostname, err := ParseHostnameBytes(buff, cursor, l)
+  return string(hostname), err
+}
+
+// ParseHostnameBytes is ParseHostname returning a slice of buff
+func ParseHostnameBytes(buff []byte, cursor *int, l int) ([]byte, error) {
   from := *cursor
   var to int
 
@@ -188,11 +359,9 @@ func ParseHostname(buff []byte, cursor *int, l int) (string, error) {
     }
   }
 
-  hostname := buff[from:to]
-
   *cursor = to
 
-  return string(hostname), nil
+  return buff[from:to], nil
 }
 
 func ShowCursorPos(buff []byte, cursor int) {
@@ -204,3 +373,15 @@ func ShowCursorPos(buff []byte, cursor int) {
 func (err *ParserError) Error() string {
   return err.ErrorString
 }
+
+func (err *PositionError) Error() string {
+  return fmt.Sprintf("%s: %s at offset %d: %s", err.Format, err.Field, err.Offset, err.Err)
+}
+
+func (err *PositionError) Unwrap() error {
+  return err.Err
+}
+
+func (v Violation) String() string {
+  return fmt.Sprintf("%s: %s at offset %d: %s", v.Format, v.Field, v.Offset, v.Reason)
+}
@@ -1,7 +1,9 @@
 package syslogparser
 
 import (
+  "errors"
   . "github.com/scalingdata/check"
+  message "github.com/scalingdata/syslogparser/message"
   "testing"
 )
 
@@ -81,6 +83,20 @@ func (s *CommonTestSuite) TestNewPriority(c *C) {
   c.Assert(obtained, DeepEquals, expected)
 }
 
+func (s *CommonTestSuite) TestComputePriority(c *C) {
+  obtained, err := ComputePriority(message.Local4, message.Notice)
+  c.Assert(err, IsNil)
+  c.Assert(obtained, DeepEquals, newPriority(165))
+
+  obtained, err = ComputePriority(message.FacilityUnknown, message.Notice)
+  c.Assert(err, Equals, ErrPriorityInvalid)
+  c.Assert(obtained, DeepEquals, newPriority(DEFAULT_PRIORITY))
+
+  obtained, err = ComputePriority(message.Kernel, message.Severity(8))
+  c.Assert(err, Equals, ErrPriorityInvalid)
+  c.Assert(obtained, DeepEquals, newPriority(DEFAULT_PRIORITY))
+}
+
 func (s *CommonTestSuite) TestParseVersion_NotFound(c *C) {
   buff := []byte("<123>")
   start := 5
@@ -92,7 +108,17 @@ func (s *CommonTestSuite) TestParseVersion_NonDigit(c *C) {
   buff := []byte("<123>a")
   start := 5
 
-  s.assertVersion(c, NO_VERSION, buff, start, start+1, nil)
+  s.assertVersion(c, NO_VERSION, buff, start, start, ErrVersionNotFound)
+}
+
+func (s *CommonTestSuite) TestParseVersion_Invalid(c *C) {
+  buff := []byte("<123>01 ")
+  start := 5
+
+  s.assertVersion(c, NO_VERSION, buff, start, start, ErrVersionInvalid)
+
+  buff = []byte("<123>1000 ")
+  s.assertVersion(c, NO_VERSION, buff, start, start, ErrVersionInvalid)
 }
 
 func (s *CommonTestSuite) TestParseVersion_Ok(c *C) {
@@ -102,6 +128,13 @@ func (s *CommonTestSuite) TestParseVersion_Ok(c *C) {
   s.assertVersion(c, 1, buff, start, start+1, nil)
 }
 
+func (s *CommonTestSuite) TestParseVersion_MultiDigit(c *C) {
+  buff := []byte("<123>123 ")
+  start := 5
+
+  s.assertVersion(c, 123, buff, start, start+3, nil)
+}
+
 func (s *CommonTestSuite) TestParseHostname_Invalid(c *C) {
   // XXX : no year specified. Assumed current year
   // XXX : no timezone specified. Assume UTC
@@ -122,6 +155,41 @@ func (s *CommonTestSuite) TestParseHostname_Valid(c *C) {
   s.assertHostname(c, hostname, buff, start, len(hostname), nil)
 }
 
+func (s *CommonTestSuite) TestPositionError(c *C) {
+  var err error = NewPositionError("RFC5424", FIELD_PRI, 4, ErrPriorityNonDigit)
+
+  c.Assert(err, ErrorMatches, "RFC5424: PRI at offset 4: Non digit found in priority")
+  c.Assert(errors.Is(err, ErrPriorityNonDigit), Equals, true)
+  c.Assert(errors.Is(err, ErrPriorityNoEnd), Equals, false)
+
+  var parseErr *PositionError
+  c.Assert(errors.As(err, &parseErr), Equals, true)
+  c.Assert(parseErr.Field, Equals, FIELD_PRI)
+  c.Assert(parseErr.Offset, Equals, 4)
+}
+
+func (s *CommonTestSuite) TestValidatePriority(c *C) {
+  fixtures := []struct {
+    buff     string
+    expected []Violation
+    err      error
+  }{
+    {"<34>", nil, nil},
+    {"<0>", nil, nil},
+    {"<034>", []Violation{{"RFC5424", FIELD_PRI, 1, "Leading zero in priority"}}, nil},
+    {"<192>", []Violation{{"RFC5424", FIELD_PRI, 1, "Priority greater than 191"}}, nil},
+    {"<34", []Violation{{"RFC5424", FIELD_PRI, 0, "No end char found for priority"}}, ErrPriorityNoEnd},
+  }
+
+  for _, f := range fixtures {
+    cursor := 0
+    buff := []byte(f.buff)
+    violations, err := ValidatePriority("RFC5424", buff, &cursor, len(buff))
+    c.Assert(violations, DeepEquals, f.expected, Commentf("%q", f.buff))
+    c.Assert(err, Equals, f.err)
+  }
+}
+
 func (s *CommonTestSuite) BenchmarkParsePriority(c *C) {
   buff := []byte("<190>")
   var start int